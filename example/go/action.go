@@ -1,15 +1,21 @@
 package main
 
 import (
-	"encoding/json"
-
 	"github.com/hgiasac/hasura-router/go/action"
 	"github.com/hgiasac/hasura-router/go/types"
 )
 
+type helloInput struct {
+	Message string `json:"message" validate:"required"`
+}
+
+type helloOutput struct {
+	Message string `json:"message"`
+}
+
 func newActionRouter() (*action.Router, error) {
 	actions, err := action.New(map[action.ActionName]action.Action{
-		"goHello":   goActionHello,
+		"goHello":   action.NewTypedAction(goActionHello),
 		"goFailure": goActionFailure,
 	})
 
@@ -19,17 +25,9 @@ func newActionRouter() (*action.Router, error) {
 	return actions.WithDebug(true), nil
 }
 
-func goActionHello(ctx *action.Context, rawBody []byte) (interface{}, error) {
-	var input struct {
-		Message string `json:"message"`
-	}
-
-	if err := json.Unmarshal(rawBody, &input); err != nil {
-		return nil, err
-	}
-
-	return map[string]interface{}{
-		"message": input.Message,
+func goActionHello(ctx *action.Context, input helloInput) (helloOutput, error) {
+	return helloOutput{
+		Message: input.Message,
 	}, nil
 }
 
@@ -1,23 +1,29 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/server"
 )
 
 func main() {
 
-	mux := http.NewServeMux()
 	actions, err := newActionRouter()
 	if err != nil {
 		panic(err)
 	}
-	mux.Handle("/actions", actions)
-	mux.Handle("/events", newEventRouter())
-	mux.Handle("/crons", newCronRouter())
 
-	log.Printf("running server at port 9001")
-	if err = http.ListenAndServe("0.0.0.0:9001", mux); err != nil {
+	metricsRecorder := metrics.NewPrometheusRecorder().WithRoles(actions.Roles()...)
+	err = server.New().
+		WithAddress("0.0.0.0:9001").
+		WithActions("/actions", actions.WithMetrics(metricsRecorder)).
+		WithEvents("/events", newEventRouter().WithMetrics(metricsRecorder)).
+		WithCrons("/crons", newCronRouter().WithMetrics(metricsRecorder)).
+		Handle("/metrics", metricsRecorder).
+		ListenAndServe(context.Background())
+
+	if err != nil {
 		panic(err)
 	}
 }
@@ -1,22 +1,44 @@
 package action
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"sort"
+	"time"
 
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/logging"
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/middleware"
 	"github.com/hgiasac/hasura-router/go/tracing"
 	"github.com/hgiasac/hasura-router/go/types"
 )
 
 // Router represent a generic action http handler
 type Router struct {
-	actions   map[ActionName]Action
-	onSuccess func(ctx *Context, response interface{}, metadata map[string]interface{})
-	onError   func(ctx *Context, err error, metadata map[string]interface{})
-	debug     bool
+	actions           map[ActionName]Action
+	onSuccess         func(ctx *Context, response interface{}, metadata map[string]interface{})
+	onError           func(ctx *Context, err error, metadata map[string]interface{})
+	onAsyncResult     func(ctx *Context, result AsyncResult, err error, metadata map[string]interface{})
+	logger            logging.Logger
+	redactor          *tracing.Redactor
+	tracer            tracing.Tracer
+	metrics           metrics.Recorder
+	debug             bool
+	repanic           bool
+	timeout           time.Duration
+	timeouts          map[ActionName]time.Duration
+	authenticator     auth.Authenticator
+	middlewares       []middleware.Middleware
+	actionMiddlewares map[ActionName][]middleware.Middleware
+	permissions       map[ActionName]Permission
+	asyncActions      map[ActionName]bool
+	async             *asyncPool
+	extractPayload    PayloadExtractor
 }
 
 // New create an Hasura action router
@@ -25,11 +47,21 @@ func New(actions map[ActionName]Action) (*Router, error) {
 		return nil, errors.New("there should be at least one action")
 	}
 
-	return &Router{
-		actions:   actions,
-		onSuccess: onSuccess,
-		onError:   onError,
-	}, nil
+	rt := &Router{
+		actions:           actions,
+		logger:            logging.NewStdLogger(nil),
+		redactor:          tracing.NewRedactor(),
+		timeouts:          make(map[ActionName]time.Duration),
+		actionMiddlewares: make(map[ActionName][]middleware.Middleware),
+		permissions:       make(map[ActionName]Permission),
+		asyncActions:      make(map[ActionName]bool),
+		extractPayload:    DecodePayload,
+	}
+	rt.onSuccess = rt.logSuccess
+	rt.onError = rt.logError
+	rt.onAsyncResult = rt.logAsyncResult
+
+	return rt, nil
 }
 
 // WithDebug set debug mode to add input data to the tracing context
@@ -38,6 +70,118 @@ func (rt *Router) WithDebug(debug bool) *Router {
 	return rt
 }
 
+// WithTimeout set the default execution timeout of all actions.
+// The timeout should be lower than the action timeout configured in Hasura, which is 30 seconds by default
+func (rt *Router) WithTimeout(timeout time.Duration) *Router {
+	rt.timeout = timeout
+	return rt
+}
+
+// WithActionTimeout set the execution timeout of an action that overrides the default timeout
+func (rt *Router) WithActionTimeout(name ActionName, timeout time.Duration) *Router {
+	rt.timeouts[name] = timeout
+	return rt
+}
+
+// WithRepanic set whether recovered handler panics are re-panicked after being reported.
+// It only takes effect in debug mode and is useful for local development
+func (rt *Router) WithRepanic(repanic bool) *Router {
+	rt.repanic = repanic
+	return rt
+}
+
+// WithAuthenticator set the authenticator that verifies requests before the body is decoded
+func (rt *Router) WithAuthenticator(authenticator auth.Authenticator) *Router {
+	rt.authenticator = authenticator
+	return rt
+}
+
+// Use appends router-wide middlewares that wrap every action invocation
+func (rt *Router) Use(middlewares ...middleware.Middleware) *Router {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+	return rt
+}
+
+// UseAction appends middlewares that only wrap the invocation of the action.
+// They are executed after router-wide middlewares
+func (rt *Router) UseAction(name ActionName, middlewares ...middleware.Middleware) *Router {
+	rt.actionMiddlewares[name] = append(rt.actionMiddlewares[name], middlewares...)
+	return rt
+}
+
+// WithPermission set the permission rules that are enforced before the action handler runs
+func (rt *Router) WithPermission(name ActionName, permission Permission) *Router {
+	rt.permissions[name] = permission
+	return rt
+}
+
+// WithPermissions set permission rules of many actions
+func (rt *Router) WithPermissions(permissions map[ActionName]Permission) *Router {
+	for name, permission := range permissions {
+		rt.permissions[name] = permission
+	}
+	return rt
+}
+
+// Permissions returns the declared permission table of actions
+func (rt *Router) Permissions() map[ActionName]Permission {
+	results := make(map[ActionName]Permission)
+	for name, permission := range rt.permissions {
+		results[name] = permission
+	}
+	return results
+}
+
+// Roles returns the sorted roles that are allowed by the permission table, including the admin role.
+// It is useful to bound the role label of metrics, e.g. recorder.WithRoles(router.Roles()...)
+func (rt *Router) Roles() []string {
+	roles := map[string]bool{types.RoleAdmin: true}
+	for _, permission := range rt.permissions {
+		for _, role := range permission.Roles {
+			roles[role] = true
+		}
+	}
+	results := make([]string, 0, len(roles))
+	for role := range roles {
+		results = append(results, role)
+	}
+	sort.Strings(results)
+	return results
+}
+
+// WithPayloadExtractor set the function that extracts the action payload from the request body.
+// It is useful for actions whose body is reshaped by a request transform
+func (rt *Router) WithPayloadExtractor(extractor PayloadExtractor) *Router {
+	rt.extractPayload = extractor
+	return rt
+}
+
+// WithLogger set the logger that the default success and error callbacks write to
+func (rt *Router) WithLogger(logger logging.Logger) *Router {
+	rt.logger = logger
+	return rt
+}
+
+// WithRedactor set the redactor that masks sensitive headers, session variables and debug fields in tracing.
+// The default redactor masks the admin secret, authorization and cookie headers. Set nil to disable the redaction
+func (rt *Router) WithRedactor(redactor *tracing.Redactor) *Router {
+	rt.redactor = redactor
+	return rt
+}
+
+// WithTracer set the tracer that starts a span per invocation.
+// The span is a child of the trace context propagated by W3C traceparent or B3 headers of the request
+func (rt *Router) WithTracer(tracer tracing.Tracer) *Router {
+	rt.tracer = tracer
+	return rt
+}
+
+// WithMetrics set the recorder that observes the latency and outcome of every invocation
+func (rt *Router) WithMetrics(recorder metrics.Recorder) *Router {
+	rt.metrics = recorder
+	return rt
+}
+
 // OnSuccess set a function to handle success callback
 func (rt *Router) OnSuccess(callback func(ctx *Context, response interface{}, metadata map[string]interface{})) {
 	rt.onSuccess = callback
@@ -53,36 +197,67 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	requestId := r.Header.Get(types.XRequestId)
 	tracer := tracing.New(requestId).WithFields(map[string]interface{}{
-		"type":         "action",
-		"http_headers": r.Header,
+		"type":         types.RouterTypeAction,
+		"http_headers": rt.redactor.RedactHeaders(r.Header),
 	})
+	spanContext, _ := tracing.StartRequestSpan(r.Context(), rt.tracer, types.RouterTypeAction, r.Header)
 	actionContext := &Context{
+		Context: tracing.NewContext(spanContext, tracer),
 		Headers: r.Header,
 		Tracing: tracer,
 	}
 
-	var payload actionBody
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		rt.onError(actionContext, err, tracer.Values())
-		sendError(w, types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("json body could not be decoded: %s", err.Error())))
+	endDecode := tracer.StartPhase(tracing.PhaseDecode)
+	body, err := io.ReadAll(r.Body)
+	endDecode()
+	if err != nil {
+		rt.reportError(actionContext, err)
+		types.WriteActionError(w, types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("failed to read the request body: %s", err.Error())))
 		return
 	}
 
+	if rt.authenticator != nil {
+		endValidate := tracer.StartPhase(tracing.PhaseValidate)
+		err := rt.authenticator.Authenticate(r, body)
+		endValidate()
+		if err != nil {
+			rt.reportError(actionContext, err)
+			types.WriteActionError(w, err)
+			return
+		}
+	}
+
+	endDecode = tracer.StartPhase(tracing.PhaseDecode)
+	payload, err := rt.extractPayload(r, body)
+	endDecode()
+	if err != nil {
+		rt.reportError(actionContext, err)
+		types.WriteActionError(w, err)
+		return
+	}
+	payload.Raw = body
+
 	tracer.WithFields(map[string]interface{}{
 		"action":            payload.Action.Name,
-		"session_variables": payload.SessionVariables,
+		"session_variables": rt.redactor.RedactSessionVariables(payload.SessionVariables),
 		"request_query":     payload.RequestQuery,
 	})
 
 	if rt.debug {
-		tracer = tracer.WithField("input", string(payload.Input))
+		tracer = tracer.WithField("input", rt.redactor.RedactJSON(payload.Input))
 	}
 
 	actionContext.RequestQuery = payload.RequestQuery
+	actionContext.ActionName = ActionName(payload.Action.Name)
+	actionContext.ActionID = payload.ActionID
+	actionContext.Payload = payload
 
-	if err := validateSessionVariables(payload.SessionVariables); err != nil {
-		rt.onError(actionContext, err, tracer.Values())
-		sendError(w, types.NewError(types.ErrCodeBadRequest, err.Error()))
+	endValidate := tracer.StartPhase(tracing.PhaseValidate)
+	err = validateSessionVariables(payload.SessionVariables)
+	endValidate()
+	if err != nil {
+		rt.reportError(actionContext, err)
+		types.WriteActionError(w, err)
 		return
 	}
 
@@ -90,31 +265,72 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	jsonBytes, response, err := rt.route(actionContext, payload)
 
 	w.Header().Set("Content-Type", "application/json")
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) {
+		rt.handlePanic(w, actionContext, panicErr)
+		return
+	}
 	if err != nil {
-		rt.onError(actionContext, err, tracer.Values())
-		sendError(w, err)
+		rt.reportError(actionContext, err)
+		types.WriteActionError(w, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 
-	rt.onSuccess(actionContext, response, tracer.Values())
+	rt.reportSuccess(actionContext, response)
 }
 
-func (rt *Router) route(ctx *Context, payload actionBody) ([]byte, interface{}, error) {
+func (rt *Router) route(ctx *Context, payload Payload) ([]byte, interface{}, error) {
 
-	execute, ok := rt.actions[ActionName(payload.Action.Name)]
+	name := ActionName(payload.Action.Name)
+	execute, ok := rt.actions[name]
 	if !ok {
 		return nil, nil, types.NewError(types.ErrCodeNotFound, fmt.Sprintf("unknown action %s", payload.Action.Name))
 	}
 
-	resp, err := execute(ctx, payload.Input)
+	if permission, ok := rt.permissions[name]; ok {
+		endValidate := ctx.Tracing.StartPhase(tracing.PhaseValidate)
+		err := permission.Check(ctx.SessionVariables)
+		endValidate()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	handler := middleware.Chain(middleware.Chain(func(mctx middleware.Context, mpayload interface{}) (interface{}, error) {
+		actionCtx, ok := mctx.(*Context)
+		if !ok {
+			actionCtx = ctx
+		}
+		actionPayload, ok := mpayload.(Payload)
+		if !ok {
+			actionPayload = payload
+		}
+		return execute(actionCtx, actionPayload.Input)
+	}, rt.actionMiddlewares[name]...), rt.middlewares...)
+
+	var resp interface{}
+	var err error
+	endHandler := ctx.Tracing.StartPhase(tracing.PhaseHandler)
+	if rt.asyncActions[name] {
+		resp, err = rt.executeAsync(ctx, name, func(ctx *Context) (interface{}, error) {
+			return handler(ctx, payload)
+		})
+	} else {
+		resp, err = rt.execute(ctx, name, func(ctx *Context) (interface{}, error) {
+			return handler(ctx, payload)
+		})
+	}
+	endHandler()
 	if err != nil {
 		return nil, nil, err
 	}
 
+	endEncode := ctx.Tracing.StartPhase(tracing.PhaseEncode)
 	bytes, jsonErr := json.Marshal(resp)
+	endEncode()
 	if jsonErr != nil {
 		return nil, nil, types.NewError(types.ErrCodeInternal, jsonErr.Error())
 	}
@@ -122,57 +338,94 @@ func (rt *Router) route(ctx *Context, payload actionBody) ([]byte, interface{},
 	return bytes, resp, nil
 }
 
-func sendError(w http.ResponseWriter, err error) {
-	w.WriteHeader(400)
-
-	var actionError types.Error
-
-	if ok := errors.As(err, &actionError); !ok {
-		actionError = types.NewError(types.ErrCodeUnknown, err.Error())
-	}
-
-	responseBytes, err := json.Marshal(actionError)
-
-	if err != nil {
-		w.Write([]byte(fmt.Sprintf(`{ "message": "ERROR: %s" }`, err)))
-		return
+// execute runs the action handler with the configured timeout and recovers panics
+func (rt *Router) execute(ctx *Context, name ActionName, handler func(ctx *Context) (interface{}, error)) (interface{}, error) {
+	timeout, ok := rt.timeouts[name]
+	if !ok {
+		timeout = rt.timeout
 	}
+	return types.ExecuteWithTimeout(ctx.Context, "action "+string(name), timeout, func(timeoutCtx context.Context) (interface{}, error) {
+		handlerCtx := *ctx
+		handlerCtx.Context = timeoutCtx
+		return handler(&handlerCtx)
+	})
+}
 
-	w.Write(responseBytes)
+// handlePanic reports the recovered panic of the handler to the error callback
+func (rt *Router) handlePanic(w http.ResponseWriter, ctx *Context, panicErr *types.PanicError) {
+	ctx.Tracing.WithFields(panicErr.Fields())
+	rt.reportError(ctx, panicErr)
+	types.WritePanicError(w, panicErr, types.WriteActionError, rt.debug && rt.repanic)
 }
 
 func validateSessionVariables(variables map[string]string) error {
 	role, hasRole := variables[types.XHasuraRole]
 
 	if !hasRole || role == "" {
-		return fmt.Errorf("%s session variable is required", types.XHasuraRole)
+		return types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("%s session variable is required", types.XHasuraRole))
 	}
 	return nil
 }
 
-func onSuccess(ctx *Context, response interface{}, metadata map[string]interface{}) {
-	metadata["level"] = "info"
-	metadata["message"] = "executed action successfully"
+// reportSuccess ends the invocation span and calls the success callback
+func (rt *Router) reportSuccess(ctx *Context, response interface{}) {
+	metadata := ctx.Tracing.Values()
+	rt.endSpan(ctx, metadata, nil)
+	rt.observe(ctx, types.RouterTypeAction, nil)
+	rt.onSuccess(ctx, response, metadata)
+}
 
-	jsonBytes, err := json.Marshal(metadata)
-	if err != nil {
-		log.Println(metadata)
+// reportError ends the invocation span with the error and calls the error callback
+func (rt *Router) reportError(ctx *Context, err error) {
+	metadata := ctx.Tracing.Values()
+	rt.endSpan(ctx, metadata, err)
+	rt.observe(ctx, types.RouterTypeAction, err)
+	rt.onError(ctx, err, metadata)
+}
+
+func (rt *Router) endSpan(ctx *Context, metadata map[string]interface{}, err error) {
+	span := tracing.SpanFromContext(ctx)
+	if ctx.ActionName != "" {
+		span.SetName(types.RouterTypeAction + " " + string(ctx.ActionName))
+	}
+	tracing.EndSpan(span, metadata, err)
+}
+
+func (rt *Router) observe(ctx *Context, routerType string, err error) {
+	if rt.metrics == nil {
 		return
 	}
+	handler := string(ctx.ActionName)
+	if _, ok := rt.actions[ctx.ActionName]; !ok {
+		handler = metrics.UnknownHandler
+	}
+	rt.metrics.Observe(metrics.Observation{
+		RouterType: routerType,
+		Handler:    handler,
+		Role:       ctx.SessionVariables.GetRole(),
+		ErrorCode:  metrics.ErrorCode(err),
+		Duration:   time.Since(ctx.Tracing.StartTime()),
+		Phases:     metrics.PhaseDurations(ctx.Tracing.Phases()),
+	})
+}
 
-	log.Println(string(jsonBytes))
+// logSuccess writes the success result to the logger. It is the default success callback
+func (rt *Router) logSuccess(ctx *Context, response interface{}, metadata map[string]interface{}) {
+	rt.logger.Log(ctx, logging.LevelInfo, "executed action successfully", metadata)
 }
 
-func onError(ctx *Context, err error, metadata map[string]interface{}) {
-	metadata["level"] = "error"
+// logError writes the error to the logger. It is the default error callback
+func (rt *Router) logError(ctx *Context, err error, metadata map[string]interface{}) {
 	metadata["error"] = err
-	metadata["message"] = err.Error()
+	rt.logger.Log(ctx, logging.LevelError, err.Error(), metadata)
+}
 
-	jsonStr, err := json.Marshal(metadata)
+// logAsyncResult writes the result of the asynchronous action to the logger. It is the default async result callback
+func (rt *Router) logAsyncResult(ctx *Context, result AsyncResult, err error, metadata map[string]interface{}) {
+	metadata["async_status"] = result.Status
 	if err != nil {
-		log.Println(metadata)
+		rt.logError(ctx, err, metadata)
 		return
 	}
-
-	log.Println(string(jsonStr))
+	rt.logger.Log(ctx, logging.LevelInfo, "executed asynchronous action successfully", metadata)
 }
@@ -0,0 +1,310 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/middleware"
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRequest(name string, input string) *http.Request {
+	body := []byte(`{"action": {"name": "` + name + `"}, "session_variables": {"x-hasura-role": "user"}, "input": ` + input + `}`)
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func TestActionTimeout(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"slow": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		"fast": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			if tracing.FromContext(ctx) != ctx.Tracing {
+				t.Error("expected the tracing instance in the request context")
+			}
+			_, hasDeadline := ctx.Deadline()
+			return map[string]bool{"deadline": hasDeadline}, nil
+		},
+	})
+	assert.NoError(t, err)
+	router.WithTimeout(time.Second).WithActionTimeout("slow", 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("slow", "{}"))
+	var actionErr types.ActionErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actionErr))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, types.ErrCodeTimeout, actionErr.Extensions["code"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("fast", "{}"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"deadline":true}`, w.Body.String())
+}
+
+func TestActionPanic(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"panic": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			panic("something went wrong")
+		},
+	})
+	assert.NoError(t, err)
+
+	var metadata map[string]interface{}
+	router.OnError(func(ctx *Context, err error, m map[string]interface{}) {
+		metadata = m
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("panic", "{}"))
+	var actionErr types.ActionErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actionErr))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, types.ErrCodeInternal, actionErr.Extensions["code"])
+	assert.Equal(t, "something went wrong", metadata["panic"])
+	assert.Contains(t, metadata["stack"], "TestActionPanic")
+
+	router.WithDebug(true).WithRepanic(true)
+	assert.PanicsWithValue(t, "something went wrong", func() {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest("panic", "{}"))
+	})
+}
+
+func TestActionPermission(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			return "hello", nil
+		},
+	})
+	assert.NoError(t, err)
+	router.WithPermission("hello", Permission{
+		Roles:            []string{"manager"},
+		SessionVariables: []string{"X-Hasura-User-Id"},
+	})
+	assert.Equal(t, []string{"manager"}, router.Permissions()["hello"].Roles)
+	router.WithPermission("bye", Permission{Roles: []string{"user", "manager"}})
+	assert.Equal(t, []string{"admin", "manager", "user"}, router.Roles())
+
+	fixtures := []struct {
+		SessionVariables string
+		StatusCode       int
+	}{
+		{`{"x-hasura-role": "user", "x-hasura-user-id": "1"}`, http.StatusUnauthorized},
+		{`{"x-hasura-role": "manager"}`, http.StatusUnauthorized},
+		{`{"x-hasura-role": "manager", "x-hasura-user-id": "1"}`, http.StatusOK},
+		{`{"x-hasura-role": "admin", "x-hasura-user-id": "1"}`, http.StatusOK},
+	}
+
+	for _, fixture := range fixtures {
+		body := []byte(`{"action": {"name": "hello"}, "session_variables": ` + fixture.SessionVariables + `, "input": {}}`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+		assert.Equal(t, fixture.StatusCode, w.Code, fixture.SessionVariables)
+	}
+}
+
+func TestActionRedaction(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"login": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			assert.Equal(t, "secret", ctx.Headers.Get(types.XHasuraAdminSecret))
+			return "ok", nil
+		},
+	})
+	assert.NoError(t, err)
+
+	var metadata map[string]interface{}
+	router.WithDebug(true).
+		WithRedactor(tracing.NewRedactor().WithPaths(tracing.MaskDrop, "password"))
+	router.OnSuccess(func(ctx *Context, response interface{}, values map[string]interface{}) {
+		metadata = values
+	})
+
+	r := newTestRequest("login", `{"email": "foo@example.com", "password": "secret"}`)
+	r.Header.Set(types.XHasuraAdminSecret, "secret")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, tracing.RedactedValue, metadata["http_headers"].(http.Header).Get(types.XHasuraAdminSecret))
+	assert.Equal(t, `{"email":"foo@example.com"}`, metadata["input"])
+}
+
+func TestActionSpan(t *testing.T) {
+	var spans []tracing.SpanData
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			_, span := tracing.StartSpan(ctx, "query_user")
+			defer span.End()
+			return "ok", nil
+		},
+	})
+	assert.NoError(t, err)
+	router.WithTracer(tracing.NewSimpleTracer(func(span tracing.SpanData) {
+		spans = append(spans, span)
+	}))
+
+	r := newTestRequest("hello", "{}")
+	r.Header.Set(tracing.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), r)
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("unknown", "{}"))
+
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "query_user", spans[0].Name)
+	assert.Equal(t, "action hello", spans[1].Name)
+	assert.Equal(t, spans[1].SpanContext, spans[0].Parent)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID)
+	assert.Equal(t, "hello", spans[1].Attributes["action"])
+	assert.Equal(t, tracing.StatusOK, spans[1].Status)
+	assert.Equal(t, "action unknown", spans[2].Name)
+	assert.Equal(t, tracing.StatusError, spans[2].Status)
+}
+
+func TestActionPhases(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			defer ctx.Tracing.StartPhase("query")()
+			return "ok", nil
+		},
+	})
+	assert.NoError(t, err)
+
+	var phases map[string]float64
+	var observation metrics.Observation
+	router.WithMetrics(metrics.RecorderFunc(func(o metrics.Observation) {
+		observation = o
+	}))
+	router.OnSuccess(func(ctx *Context, response interface{}, metadata map[string]interface{}) {
+		phases = metadata["measurement"].(map[string]interface{})["phases"].(map[string]float64)
+	})
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("hello", "{}"))
+
+	for _, name := range []string{tracing.PhaseDecode, tracing.PhaseValidate, tracing.PhaseHandler, tracing.PhaseEncode, "query"} {
+		assert.Contains(t, phases, name)
+		assert.Contains(t, observation.Phases, name)
+	}
+}
+
+func TestActionMetricsHandler(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			return "ok", nil
+		},
+	})
+	assert.NoError(t, err)
+
+	var handlers []string
+	router.WithMetrics(metrics.RecorderFunc(func(o metrics.Observation) {
+		handlers = append(handlers, o.Handler)
+	}))
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("hello", "{}"))
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("random_1", "{}"))
+
+	assert.Equal(t, []string{"hello", metrics.UnknownHandler}, handlers)
+}
+
+func TestActionAuthentication(t *testing.T) {
+	var called bool
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			called = true
+			return "hello", nil
+		},
+	})
+	assert.NoError(t, err)
+	router.WithAuthenticator(auth.NewSharedSecret("secret"))
+
+	// the malformed body would fail with 400 if it were decoded before authentication
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{"))))
+	var actionErr types.ActionErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &actionErr))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, types.ErrCodeUnauthorized, actionErr.Extensions["code"])
+	assert.False(t, called)
+
+	r := newTestRequest("hello", "{}")
+	r.Header.Set(auth.DefaultSecretHeader, "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+}
+
+func TestActionMiddlewares(t *testing.T) {
+	var calls []string
+	newMiddleware := func(name string) middleware.Middleware {
+		return func(next middleware.Handler) middleware.Handler {
+			return func(ctx middleware.Context, payload interface{}) (interface{}, error) {
+				calls = append(calls, name)
+				resp, err := next(ctx, payload)
+				calls = append(calls, name+"_done")
+				return resp, err
+			}
+		}
+	}
+
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			calls = append(calls, "handler")
+			return json.RawMessage(rawBody), nil
+		},
+		"cached": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			calls = append(calls, "handler")
+			return "fresh", nil
+		},
+		"forbidden": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			calls = append(calls, "handler")
+			return "ok", nil
+		},
+	})
+	assert.NoError(t, err)
+	router.Use(newMiddleware("a"), newMiddleware("b")).
+		UseAction("hello", newMiddleware("c"), func(next middleware.Handler) middleware.Handler {
+			return func(ctx middleware.Context, payload interface{}) (interface{}, error) {
+				p := payload.(Payload)
+				p.Input = json.RawMessage(`{"rewritten":true}`)
+				return next(ctx, p)
+			}
+		}).
+		UseAction("cached", func(next middleware.Handler) middleware.Handler {
+			return func(ctx middleware.Context, payload interface{}) (interface{}, error) {
+				calls = append(calls, "cache")
+				return "cached", nil
+			}
+		}).
+		UseAction("forbidden", func(next middleware.Handler) middleware.Handler {
+			return func(ctx middleware.Context, payload interface{}) (interface{}, error) {
+				calls = append(calls, "deny")
+				return nil, types.NewError(types.ErrCodeUnauthorized, "denied by middleware")
+			}
+		})
+
+	fixtures := []struct {
+		Name       string
+		StatusCode int
+		Response   string
+		Calls      []string
+	}{
+		{"hello", http.StatusOK, `{"rewritten":true}`, []string{"a", "b", "c", "handler", "c_done", "b_done", "a_done"}},
+		{"cached", http.StatusOK, `"cached"`, []string{"a", "b", "cache", "b_done", "a_done"}},
+		{"forbidden", http.StatusUnauthorized, `{"message":"denied by middleware","extensions":{"code":"unauthorized"}}`, []string{"a", "b", "deny", "b_done", "a_done"}},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			calls = nil
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newTestRequest(fixture.Name, `{}`))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+			assert.Equal(t, fixture.Response, w.Body.String())
+			assert.Equal(t, fixture.Calls, calls)
+		})
+	}
+}
@@ -0,0 +1,389 @@
+package action
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+const (
+	DefaultAsyncWorkers   = 10
+	DefaultAsyncQueueSize = 100
+	DefaultResultCapacity = 10000
+)
+
+// AsyncStatus represents the execution status of an asynchronous action
+type AsyncStatus string
+
+const (
+	AsyncStatusPending   AsyncStatus = "pending"
+	AsyncStatusCompleted AsyncStatus = "completed"
+	AsyncStatusFailed    AsyncStatus = "failed"
+)
+
+// AsyncResponse represents the acknowledgement response of an asynchronous action
+type AsyncResponse struct {
+	ActionID string `json:"action_id"`
+}
+
+// AsyncResult represents the execution result of an asynchronous action
+type AsyncResult struct {
+	ActionID   string          `json:"action_id"`
+	ActionName ActionName      `json:"action_name"`
+	Status     AsyncStatus     `json:"status"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      *types.Error    `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	UpdatedAt  time.Time       `json:"updated_at"`
+}
+
+// ResultStore represents a pluggable storage of asynchronous action results
+type ResultStore interface {
+	// Save creates or replaces the result of the action
+	Save(ctx context.Context, result AsyncResult) error
+	// Get returns the result of the action id, or nil if it doesn't exist
+	Get(ctx context.Context, actionID string) (*AsyncResult, error)
+}
+
+type memoryResult struct {
+	result    AsyncResult
+	expiresAt time.Time
+}
+
+// MemoryResultStore is an in-memory ResultStore that evicts the oldest results beyond the capacity
+type MemoryResultStore struct {
+	sync.Mutex
+	capacity int
+	ttl      time.Duration
+	results  map[string]*list.Element
+	order    *list.List
+}
+
+// NewMemoryResultStore creates an in-memory result store with the maximum number of results
+func NewMemoryResultStore(capacity int) *MemoryResultStore {
+	if capacity <= 0 {
+		capacity = DefaultResultCapacity
+	}
+	return &MemoryResultStore{
+		capacity: capacity,
+		results:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// WithTTL set the duration that results are kept after the last update.
+// Results are kept until evicted if the ttl is zero
+func (ms *MemoryResultStore) WithTTL(ttl time.Duration) *MemoryResultStore {
+	ms.ttl = ttl
+	return ms
+}
+
+// Save implements the ResultStore interface
+func (ms *MemoryResultStore) Save(ctx context.Context, result AsyncResult) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	entry := &memoryResult{result: result}
+	if ms.ttl > 0 {
+		entry.expiresAt = time.Now().Add(ms.ttl)
+	}
+
+	if element, ok := ms.results[result.ActionID]; ok {
+		element.Value = entry
+		ms.order.MoveToFront(element)
+		return nil
+	}
+
+	ms.results[result.ActionID] = ms.order.PushFront(entry)
+	for ms.order.Len() > ms.capacity {
+		oldest := ms.order.Back()
+		ms.order.Remove(oldest)
+		delete(ms.results, oldest.Value.(*memoryResult).result.ActionID)
+	}
+	return nil
+}
+
+// Get implements the ResultStore interface
+func (ms *MemoryResultStore) Get(ctx context.Context, actionID string) (*AsyncResult, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	element, ok := ms.results[actionID]
+	if !ok {
+		return nil, nil
+	}
+	entry := element.Value.(*memoryResult)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		ms.order.Remove(element)
+		delete(ms.results, actionID)
+		return nil, nil
+	}
+	result := entry.result
+	return &result, nil
+}
+
+// Delete removes the result of the action id
+func (ms *MemoryResultStore) Delete(ctx context.Context, actionID string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	if element, ok := ms.results[actionID]; ok {
+		ms.order.Remove(element)
+		delete(ms.results, actionID)
+	}
+	return nil
+}
+
+// Len returns the number of stored results
+func (ms *MemoryResultStore) Len() int {
+	ms.Lock()
+	defer ms.Unlock()
+	return ms.order.Len()
+}
+
+type asyncJob struct {
+	ctx     *Context
+	name    ActionName
+	result  AsyncResult
+	handler func(ctx *Context) (interface{}, error)
+}
+
+// asyncPool runs asynchronous actions on a bounded number of workers
+type asyncPool struct {
+	sync.RWMutex
+	store     ResultStore
+	jobs      chan asyncJob
+	waitGroup sync.WaitGroup
+	closed    bool
+}
+
+// WithAsync enables asynchronous actions with the result store and a bounded worker pool.
+// Actions registered by WithAsyncActions are acknowledged once the handler body is queued, see WithAsyncActions
+func (rt *Router) WithAsync(store ResultStore, workers int, queueSize int) *Router {
+	if workers <= 0 {
+		workers = DefaultAsyncWorkers
+	}
+	if queueSize < 0 {
+		queueSize = DefaultAsyncQueueSize
+	}
+
+	pool := &asyncPool{
+		store: store,
+		jobs:  make(chan asyncJob, queueSize),
+	}
+	for i := 0; i < workers; i++ {
+		pool.waitGroup.Add(1)
+		go func() {
+			defer pool.waitGroup.Done()
+			for job := range pool.jobs {
+				rt.runAsync(pool, job)
+			}
+		}()
+	}
+	rt.async = pool
+
+	return rt
+}
+
+// WithAsyncActions registers actions that are executed asynchronously.
+// Middlewares, and the input decoding and validation of typed actions, run before the acknowledgement,
+// so they can still reject the request. Only the handler body passed to Context.Dispatch runs on workers.
+// Actions that don't call Context.Dispatch are executed before the acknowledgement and their response is saved as the result
+func (rt *Router) WithAsyncActions(names ...ActionName) *Router {
+	for _, name := range names {
+		rt.asyncActions[name] = true
+	}
+	return rt
+}
+
+// OnAsyncResult set a function to handle the completion of asynchronous actions.
+// The success and error callbacks only report the acknowledgement of asynchronous actions.
+// The error is not nil if the action failed or its result could not be saved
+func (rt *Router) OnAsyncResult(callback func(ctx *Context, result AsyncResult, err error, metadata map[string]interface{})) {
+	rt.onAsyncResult = callback
+}
+
+// Result returns the result of an asynchronous action, or nil if it doesn't exist
+func (rt *Router) Result(ctx context.Context, actionID string) (*AsyncResult, error) {
+	if rt.async == nil {
+		return nil, errors.New("asynchronous actions are not enabled")
+	}
+	return rt.async.store.Get(ctx, actionID)
+}
+
+// Shutdown stops accepting asynchronous actions and waits for in-flight ones to finish
+func (rt *Router) Shutdown(ctx context.Context) error {
+	if rt.async == nil {
+		return nil
+	}
+
+	rt.async.Lock()
+	if !rt.async.closed {
+		rt.async.closed = true
+		close(rt.async.jobs)
+	}
+	rt.async.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		rt.async.waitGroup.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue acknowledges the asynchronous action and pushes the handler body to the worker queue.
+// A redelivered action id is acknowledged again without being executed unless its previous execution failed
+func (rt *Router) enqueue(ctx *Context, name ActionName, handler func(ctx *Context) (interface{}, error)) (interface{}, error) {
+	if rt.async == nil {
+		return nil, types.NewError(types.ErrCodeInternal, fmt.Sprintf("action %s is asynchronous but asynchronous actions are not enabled", name))
+	}
+
+	existing, err := rt.async.store.Get(ctx, ctx.ActionID)
+	if err != nil {
+		return nil, types.NewError(types.ErrCodeInternal, fmt.Sprintf("failed to get the action result: %s", err))
+	}
+	if existing != nil && existing.Status != AsyncStatusFailed {
+		ctx.Tracing.WithField("async_redelivery", true)
+		return AsyncResponse{ActionID: ctx.ActionID}, nil
+	}
+
+	// the request context is canceled after the acknowledgement is sent
+	jobTracer := ctx.Tracing.Clone().WithField("async", true)
+	jobCtx := *ctx
+	jobCtx.Context = tracing.NewContext(tracing.Detach(ctx), jobTracer)
+	jobCtx.Tracing = jobTracer
+	jobCtx.enqueue = nil
+
+	now := time.Now()
+	result := AsyncResult{
+		ActionID:   ctx.ActionID,
+		ActionName: name,
+		Status:     AsyncStatusPending,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+
+	rt.async.RLock()
+	defer rt.async.RUnlock()
+	if rt.async.closed {
+		return nil, types.NewError(types.ErrCodeUnavailable, "the server is shutting down")
+	}
+
+	if err := rt.async.store.Save(ctx, result); err != nil {
+		return nil, types.NewError(types.ErrCodeInternal, fmt.Sprintf("failed to save the action result: %s", err))
+	}
+
+	job := asyncJob{
+		ctx:     &jobCtx,
+		name:    name,
+		result:  result,
+		handler: handler,
+	}
+	select {
+	case rt.async.jobs <- job:
+		return AsyncResponse{ActionID: ctx.ActionID}, nil
+	default:
+		// the saved pending result is never picked up by workers
+		queueErr := types.NewError(types.ErrCodeUnavailable, "the asynchronous action queue is full")
+		result.Status = AsyncStatusFailed
+		result.Error = &queueErr
+		result.UpdatedAt = time.Now()
+		if err := rt.async.store.Save(ctx, result); err != nil {
+			ctx.Tracing.WithField("save_error", err.Error())
+		}
+		return nil, queueErr
+	}
+}
+
+// executeAsync runs the middlewares and the action until the handler body is dispatched to the worker pool.
+// If the action returns without dispatching, e.g. a middleware short-circuits, its response is saved as the result
+func (rt *Router) executeAsync(ctx *Context, name ActionName, handler func(ctx *Context) (interface{}, error)) (interface{}, error) {
+	if ctx.ActionID == "" {
+		ctx.ActionID = uuid.New().String()
+	}
+	ctx.Tracing.WithField("action_id", ctx.ActionID)
+
+	var lock sync.Mutex
+	var dispatched, done bool
+	ctx.enqueue = func(dispatchCtx *Context, body func(ctx *Context) (interface{}, error)) (interface{}, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		// the handler may still run after the timeout
+		if done {
+			return nil, types.NewError(types.ErrCodeTimeout, fmt.Sprintf("action %s was dispatched after the timeout", name))
+		}
+		dispatched = true
+		return rt.enqueue(dispatchCtx, name, body)
+	}
+	resp, err := rt.execute(ctx, name, handler)
+	ctx.enqueue = nil
+
+	lock.Lock()
+	done = true
+	lock.Unlock()
+	if err != nil || dispatched {
+		return resp, err
+	}
+	return rt.enqueue(ctx, name, func(ctx *Context) (interface{}, error) {
+		return resp, nil
+	})
+}
+
+func (rt *Router) runAsync(pool *asyncPool, job asyncJob) {
+	job.ctx.Context, _ = tracing.TracerFromContext(job.ctx).Start(job.ctx, string(job.name), tracing.SpanKindInternal)
+	endHandler := job.ctx.Tracing.StartPhase(tracing.PhaseHandler)
+	resp, err := rt.execute(job.ctx, job.name, job.handler)
+	endHandler()
+
+	result := job.result
+	result.UpdatedAt = time.Now()
+	if err == nil {
+		endEncode := job.ctx.Tracing.StartPhase(tracing.PhaseEncode)
+		result.Response, err = json.Marshal(resp)
+		endEncode()
+	}
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) {
+		job.ctx.Tracing.WithFields(panicErr.Fields())
+		err = types.NewError(types.ErrCodeInternal, "internal server error")
+	}
+	if err != nil {
+		actionError := types.ToError(err)
+		result.Status = AsyncStatusFailed
+		result.Error = &actionError
+	} else {
+		result.Status = AsyncStatusCompleted
+	}
+
+	if saveErr := pool.store.Save(context.Background(), result); saveErr != nil {
+		job.ctx.Tracing.WithField("save_error", saveErr.Error())
+		if err == nil {
+			err = saveErr
+		}
+	}
+
+	rt.reportAsyncResult(job.ctx, result, err)
+}
+
+// reportAsyncResult ends the span of the asynchronous execution and calls the async result callback.
+// The completion is observed separately from the acknowledgement that is reported by the request
+func (rt *Router) reportAsyncResult(ctx *Context, result AsyncResult, err error) {
+	metadata := ctx.Tracing.Values()
+	rt.endSpan(ctx, metadata, err)
+	rt.observe(ctx, types.RouterTypeAsyncAction, err)
+	rt.onAsyncResult(ctx, result, err, metadata)
+}
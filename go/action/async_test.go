@@ -0,0 +1,230 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/middleware"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsyncAction(t *testing.T) {
+	store := NewMemoryResultStore(0)
+	router, err := New(map[ActionName]Action{
+		"hello": NewTypedAction(func(ctx *Context, input helloInput) (helloOutput, error) {
+			return helloOutput{Message: "hello " + ctx.ActionID}, nil
+		}),
+	})
+	assert.NoError(t, err)
+	router.WithAsync(store, 1, 1).WithAsyncActions("hello")
+
+	body := []byte(`{"action": {"name": "hello"}, "action_id": "foo", "session_variables": {"x-hasura-role": "user"}, "input": {}}`)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"action_id":"foo"}`, w.Body.String())
+
+	assert.NoError(t, router.Shutdown(context.Background()))
+
+	result, err := router.Result(context.Background(), "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, AsyncStatusCompleted, result.Status)
+	assert.Equal(t, json.RawMessage(`{"message":"hello foo"}`), result.Response)
+
+	body = []byte(`{"action": {"name": "hello"}, "action_id": "bar", "session_variables": {"x-hasura-role": "user"}, "input": {}}`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"service_unavailable"`)
+}
+
+func TestAsyncActionOutcomes(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			return ctx.Dispatch(func(ctx *Context) (interface{}, error) {
+				started <- struct{}{}
+				<-release
+				return "hello", nil
+			})
+		},
+	})
+	assert.NoError(t, err)
+
+	var lock sync.Mutex
+	var acks, results int
+	var observations []metrics.Observation
+	router.OnSuccess(func(ctx *Context, response interface{}, metadata map[string]interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		acks++
+	})
+	router.OnAsyncResult(func(ctx *Context, result AsyncResult, err error, metadata map[string]interface{}) {
+		lock.Lock()
+		defer lock.Unlock()
+		results++
+		assert.NoError(t, err)
+		assert.Equal(t, AsyncStatusCompleted, result.Status)
+	})
+	store := NewMemoryResultStore(0)
+	router.WithAsync(store, 1, 1).WithAsyncActions("hello").
+		WithMetrics(metrics.RecorderFunc(func(observation metrics.Observation) {
+			lock.Lock()
+			defer lock.Unlock()
+			observations = append(observations, observation)
+		}))
+
+	newAsyncRequest := func(actionID string) *http.Request {
+		body := []byte(`{"action": {"name": "hello"}, "action_id": "` + actionID + `", "session_variables": {"x-hasura-role": "user"}, "input": {}}`)
+		return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	}
+
+	// the first action occupies the worker and the second one fills the queue
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newAsyncRequest("running"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	<-started
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAsyncRequest("queued"))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newAsyncRequest("rejected"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"service_unavailable"`)
+	result, err := router.Result(context.Background(), "rejected")
+	assert.NoError(t, err)
+	assert.Equal(t, AsyncStatusFailed, result.Status)
+	assert.Equal(t, types.ErrCodeUnavailable, result.Error.Code)
+
+	close(release)
+	assert.NoError(t, router.Shutdown(context.Background()))
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Equal(t, 2, acks)
+	assert.Equal(t, 2, results)
+
+	routerTypes := map[string]int{}
+	for _, observation := range observations {
+		routerTypes[observation.RouterType]++
+	}
+	assert.Equal(t, map[string]int{
+		types.RouterTypeAction:      3,
+		types.RouterTypeAsyncAction: 2,
+	}, routerTypes)
+}
+
+type asyncInput struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func TestAsyncActionRejection(t *testing.T) {
+	var lock sync.Mutex
+	var executions []string
+	router, err := New(map[ActionName]Action{
+		"hello": NewTypedAction(func(ctx *Context, input asyncInput) (string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			executions = append(executions, input.Name)
+			return "hello " + input.Name, nil
+		}),
+	})
+	assert.NoError(t, err)
+	router.Use(func(next middleware.Handler) middleware.Handler {
+		return func(ctx middleware.Context, payload interface{}) (interface{}, error) {
+			switch ctx.GetSessionVariables().GetRole() {
+			case "anonymous":
+				return nil, types.NewError(types.ErrCodeUnauthorized, "unauthorized")
+			case "cached":
+				return "cached", nil
+			}
+			return next(ctx, payload)
+		}
+	})
+	store := NewMemoryResultStore(0)
+	router.WithAsync(store, 1, 10).WithAsyncActions("hello")
+
+	newAsyncRequest := func(actionID string, role string, input string) *http.Request {
+		body := []byte(`{"action": {"name": "hello"}, "action_id": "` + actionID + `", "session_variables": {"x-hasura-role": "` + role + `"}, "input": ` + input + `}`)
+		return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	}
+
+	fixtures := []struct {
+		Name       string
+		ActionID   string
+		Role       string
+		Input      string
+		StatusCode int
+		Status     AsyncStatus
+		Response   string
+	}{
+		{"unauthorized", "unauthorized", "anonymous", `{"name": "foo"}`, http.StatusUnauthorized, "", ""},
+		{"invalid", "invalid", "user", `{}`, http.StatusBadRequest, "", ""},
+		{"short_circuit", "cached", "cached", `{"name": "foo"}`, http.StatusOK, AsyncStatusCompleted, `"cached"`},
+		{"dispatched", "foo", "user", `{"name": "foo"}`, http.StatusOK, AsyncStatusCompleted, `"hello foo"`},
+		{"redelivered", "foo", "user", `{"name": "bar"}`, http.StatusOK, AsyncStatusCompleted, `"hello foo"`},
+	}
+
+	for _, fixture := range fixtures {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newAsyncRequest(fixture.ActionID, fixture.Role, fixture.Input))
+		assert.Equal(t, fixture.StatusCode, w.Code, fixture.Name)
+		if fixture.StatusCode == http.StatusOK {
+			assert.Equal(t, `{"action_id":"`+fixture.ActionID+`"}`, w.Body.String(), fixture.Name)
+		}
+		// wait for the worker so the redelivery sees the completed result
+		assert.Eventually(t, func() bool {
+			result, err := router.Result(context.Background(), fixture.ActionID)
+			if fixture.Status == "" {
+				return err == nil && result == nil
+			}
+			return err == nil && result != nil && result.Status == fixture.Status && string(result.Response) == fixture.Response
+		}, time.Second, time.Millisecond, fixture.Name)
+	}
+
+	assert.NoError(t, router.Shutdown(context.Background()))
+	assert.Equal(t, []string{"foo"}, executions)
+}
+
+func TestMemoryResultStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryResultStore(2)
+	for _, id := range []string{"a", "b", "c"} {
+		assert.NoError(t, store.Save(ctx, AsyncResult{ActionID: id, Status: AsyncStatusPending}))
+	}
+	assert.Equal(t, 2, store.Len())
+
+	result, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	assert.NoError(t, store.Save(ctx, AsyncResult{ActionID: "b", Status: AsyncStatusCompleted}))
+	assert.NoError(t, store.Save(ctx, AsyncResult{ActionID: "d", Status: AsyncStatusPending}))
+	result, err = store.Get(ctx, "b")
+	assert.NoError(t, err)
+	assert.Equal(t, AsyncStatusCompleted, result.Status)
+	result, err = store.Get(ctx, "c")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+
+	assert.NoError(t, store.Delete(ctx, "b"))
+	assert.Equal(t, 1, store.Len())
+
+	store = NewMemoryResultStore(0).WithTTL(10 * time.Millisecond)
+	assert.NoError(t, store.Save(ctx, AsyncResult{ActionID: "a"}))
+	time.Sleep(20 * time.Millisecond)
+	result, err = store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, result)
+	assert.Equal(t, 0, store.Len())
+}
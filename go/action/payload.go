@@ -0,0 +1,96 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// PayloadExtractor extracts the action payload from the request body
+type PayloadExtractor func(r *http.Request, body []byte) (Payload, error)
+
+// DecodePayload is the default payload extractor that decodes the Hasura action webhook payload
+func DecodePayload(r *http.Request, body []byte) (Payload, error) {
+	var payload Payload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return payload, types.NewDecodeError(err)
+	}
+	return payload, nil
+}
+
+// PayloadFields configures dot-separated JSON paths of payload fields in a body reshaped by a request transform
+type PayloadFields struct {
+	ActionName       string
+	ActionID         string
+	Input            string
+	SessionVariables string
+	RequestQuery     string
+	// DefaultActionName is used if the action name path is empty or missing in the body
+	DefaultActionName ActionName
+}
+
+// NewFieldsExtractor creates a payload extractor that reads payload fields from JSON paths of the body.
+// The whole body is used as the action input if the input path is empty
+func NewFieldsExtractor(fields PayloadFields) PayloadExtractor {
+	return func(r *http.Request, body []byte) (Payload, error) {
+		payload := Payload{
+			Action: ActionInfo{
+				Name: string(fields.DefaultActionName),
+			},
+			Input: body,
+		}
+
+		if err := decodePath(body, fields.ActionName, &payload.Action.Name); err != nil {
+			return payload, err
+		}
+		if err := decodePath(body, fields.ActionID, &payload.ActionID); err != nil {
+			return payload, err
+		}
+		if err := decodePath(body, fields.SessionVariables, &payload.SessionVariables); err != nil {
+			return payload, err
+		}
+		if err := decodePath(body, fields.RequestQuery, &payload.RequestQuery); err != nil {
+			return payload, err
+		}
+		if fields.Input != "" {
+			payload.Input = nil
+			if err := decodePath(body, fields.Input, &payload.Input); err != nil {
+				return payload, err
+			}
+		}
+
+		return payload, nil
+	}
+}
+
+// decodePath decodes the value at the dot-separated path of the JSON body.
+// The target is unchanged if the path is empty or missing
+func decodePath(body []byte, path string, target interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	value := json.RawMessage(body)
+	for _, key := range strings.Split(path, ".") {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(value, &object); err != nil {
+			return types.NewDecodeError(err, path)
+		}
+		next, ok := object[key]
+		if !ok {
+			return nil
+		}
+		value = next
+	}
+
+	if bytes.Equal(value, []byte("null")) {
+		return nil
+	}
+	if err := json.Unmarshal(value, target); err != nil {
+		return types.NewDecodeError(err, path)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldsExtractor(t *testing.T) {
+	extractor := NewFieldsExtractor(PayloadFields{
+		ActionName:        "meta.action",
+		ActionID:          "meta.id",
+		Input:             "data.input",
+		SessionVariables:  "meta.session",
+		RequestQuery:      "meta.query",
+		DefaultActionName: "fallback",
+	})
+
+	fixtures := []struct {
+		Name     string
+		Body     string
+		Expected Payload
+		Error    bool
+	}{
+		{
+			Name: "all_fields",
+			Body: `{"meta": {"action": "hello", "id": "1", "session": {"x-hasura-role": "user"}, "query": "{ hello }"}, "data": {"input": {"name": "foo"}}}`,
+			Expected: Payload{
+				Action:           ActionInfo{Name: "hello"},
+				ActionID:         "1",
+				Input:            json.RawMessage(`{"name": "foo"}`),
+				SessionVariables: map[string]string{"x-hasura-role": "user"},
+				RequestQuery:     "{ hello }",
+			},
+		},
+		{
+			Name: "missing_paths",
+			Body: `{"meta": {"action": null}}`,
+			Expected: Payload{
+				Action: ActionInfo{Name: "fallback"},
+			},
+		},
+		{
+			Name:  "invalid_type",
+			Body:  `{"meta": {"action": 1}}`,
+			Error: true,
+		},
+		{
+			Name:  "invalid_object",
+			Body:  `{"meta": "hello"}`,
+			Error: true,
+		},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			payload, err := extractor(httptest.NewRequest(http.MethodPost, "/", nil), []byte(fixture.Body))
+			if fixture.Error {
+				assert.Equal(t, types.ErrCodeBadRequest, types.ToError(err).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, fixture.Expected, payload)
+		})
+	}
+}
+
+func TestFieldsExtractorWholeBody(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"hello": func(ctx *Context, rawBody []byte) (interface{}, error) {
+			return json.RawMessage(rawBody), nil
+		},
+	})
+	assert.NoError(t, err)
+	router.WithPayloadExtractor(NewFieldsExtractor(PayloadFields{
+		SessionVariables:  "session",
+		DefaultActionName: "hello",
+	}))
+
+	body := `{"session":{"x-hasura-role":"user"},"name":"foo"}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body))))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.String())
+}
@@ -0,0 +1,46 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// Permission represents the access control rules of an action that are enforced before the handler runs.
+// The admin role is allowed to execute any action unless other rules are unsatisfied
+type Permission struct {
+	// Roles lists the roles that are allowed to execute the action. All roles are allowed if empty
+	Roles []string `json:"roles,omitempty"`
+	// SessionVariables lists the session variables that are required, e.g. x-hasura-user-id
+	SessionVariables []string `json:"session_variables,omitempty"`
+	// AdminOnly only allows the admin role to execute the action
+	AdminOnly bool `json:"admin_only,omitempty"`
+	// BackendOnly requires the x-hasura-use-backend-only-permissions session variable to be true
+	BackendOnly bool `json:"backend_only,omitempty"`
+}
+
+// Check validates the session variables against the permission rules
+func (p Permission) Check(sv types.SessionVariables) error {
+	role := sv.GetRole()
+	isAdmin := sv.IsAdmin()
+	if p.AdminOnly && !isAdmin {
+		return types.NewError(types.ErrCodeUnauthorized, fmt.Sprintf("role %s is not allowed, admin only", role))
+	}
+
+	if len(p.Roles) > 0 && !isAdmin && !sv.IsRoleOf(p.Roles...) {
+		return types.NewError(types.ErrCodeUnauthorized, fmt.Sprintf("role %s is not allowed", role))
+	}
+
+	if p.BackendOnly && !strings.EqualFold(sv.Get(types.XHasuraUseBackendOnlyPermissions), "true") {
+		return types.NewError(types.ErrCodeUnauthorized, fmt.Sprintf("%s session variable must be true", types.XHasuraUseBackendOnlyPermissions))
+	}
+
+	for _, key := range p.SessionVariables {
+		if sv.Get(key) == "" {
+			return types.NewError(types.ErrCodeUnauthorized, fmt.Sprintf("%s session variable is required", strings.ToLower(key)))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,498 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// maxFragmentDepth limits the nesting depth of fragment expansion
+	maxFragmentDepth = 32
+	// maxExpandedSelections limits the total number of selections visited when fragments are expanded,
+	// so repeated spreads of nested fragments can't blow up exponentially
+	maxExpandedSelections = 10000
+)
+
+// OperationType represents the type of a GraphQL operation
+type OperationType string
+
+const (
+	OperationQuery        OperationType = "query"
+	OperationMutation     OperationType = "mutation"
+	OperationSubscription OperationType = "subscription"
+)
+
+// Operation represents the parsed GraphQL operation of the action request query
+type Operation struct {
+	Type OperationType
+	// Name is the operation name, empty if anonymous
+	Name string
+	// FieldName is the root field name of the action
+	FieldName string
+	// Alias is the alias of the root field, empty if not aliased
+	Alias string
+	// SelectedFields lists dot-separated paths of fields selected in the root field, e.g. user.email
+	SelectedFields []string
+}
+
+// IsSelected checks if the dot-separated field path is selected in the root field
+func (op Operation) IsSelected(path string) bool {
+	for _, field := range op.SelectedFields {
+		if field == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseOperation parses the GraphQL query and returns the operation that contains the root field.
+// The first root field is used if there is no field matching the name
+func ParseOperation(query string, fieldName string) (*Operation, error) {
+	p := &queryParser{
+		lexer:     &queryLexer{input: query},
+		fragments: make(map[string][]querySelection),
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var operations []queryOperation
+	for p.token.kind != tokenEOF {
+		operation, err := p.parseDefinition()
+		if err != nil {
+			return nil, err
+		}
+		if operation != nil {
+			operations = append(operations, *operation)
+		}
+	}
+
+	var result *Operation
+	for _, operation := range operations {
+		fields := p.flatten(operation.selections, 0)
+		if err := p.checkExpanded(); err != nil {
+			return nil, err
+		}
+		for _, field := range fields {
+			if field.name != fieldName && result != nil {
+				continue
+			}
+			children := p.flatten(field.children, 0)
+			if err := p.checkExpanded(); err != nil {
+				return nil, err
+			}
+			result = &Operation{
+				Type:           operation.kind,
+				Name:           operation.name,
+				FieldName:      field.name,
+				Alias:          field.alias,
+				SelectedFields: fieldPaths("", children),
+			}
+			if field.name == fieldName {
+				return result, nil
+			}
+		}
+	}
+
+	if result == nil {
+		return nil, fmt.Errorf("the query doesn't have any operation")
+	}
+	return result, nil
+}
+
+func fieldPaths(prefix string, selections []querySelection) []string {
+	var results []string
+	seen := make(map[string]bool)
+	for _, field := range selections {
+		path := prefix + field.name
+		if !seen[path] {
+			seen[path] = true
+			results = append(results, path)
+		}
+		for _, child := range fieldPaths(path+".", field.children) {
+			if !seen[child] {
+				seen[child] = true
+				results = append(results, child)
+			}
+		}
+	}
+	return results
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenPunctuator
+	tokenValue
+)
+
+type queryToken struct {
+	kind  tokenKind
+	value string
+}
+
+type queryLexer struct {
+	input string
+	pos   int
+}
+
+func (l *queryLexer) next() (queryToken, error) {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.input[l.pos:], "\ufeff"):
+			l.pos += len("\ufeff")
+		case strings.HasPrefix(l.input[l.pos:], "..."):
+			l.pos += 3
+			return queryToken{kind: tokenPunctuator, value: "..."}, nil
+		case strings.ContainsRune("!$&()/:=@[]{}|", rune(c)):
+			l.pos++
+			return queryToken{kind: tokenPunctuator, value: string(c)}, nil
+		case c == '"':
+			return l.readString()
+		case c == '_' || isLetter(c):
+			start := l.pos
+			for l.pos < len(l.input) && (l.input[l.pos] == '_' || isLetter(l.input[l.pos]) || isDigit(l.input[l.pos])) {
+				l.pos++
+			}
+			return queryToken{kind: tokenName, value: l.input[start:l.pos]}, nil
+		case c == '-' || isDigit(c):
+			start := l.pos
+			l.pos++
+			for l.pos < len(l.input) && strings.ContainsRune("0123456789.eE+-", rune(l.input[l.pos])) {
+				l.pos++
+			}
+			return queryToken{kind: tokenValue, value: l.input[start:l.pos]}, nil
+		default:
+			return queryToken{}, fmt.Errorf("unexpected character %q at position %d", c, l.pos)
+		}
+	}
+
+	return queryToken{kind: tokenEOF}, nil
+}
+
+func (l *queryLexer) readString() (queryToken, error) {
+	start := l.pos
+	if strings.HasPrefix(l.input[l.pos:], `"""`) {
+		l.pos += 3
+		for l.pos < len(l.input) {
+			switch {
+			case strings.HasPrefix(l.input[l.pos:], `\"""`):
+				l.pos += 4
+			case strings.HasPrefix(l.input[l.pos:], `"""`):
+				l.pos += 3
+				return queryToken{kind: tokenValue, value: l.input[start:l.pos]}, nil
+			default:
+				l.pos++
+			}
+		}
+		return queryToken{}, fmt.Errorf("unterminated block string at position %d", start)
+	}
+
+	l.pos++
+	for l.pos < len(l.input) {
+		switch l.input[l.pos] {
+		case '\\':
+			l.pos += 2
+		case '"':
+			l.pos++
+			return queryToken{kind: tokenValue, value: l.input[start:l.pos]}, nil
+		case '\n':
+			return queryToken{}, fmt.Errorf("unterminated string at position %d", start)
+		default:
+			l.pos++
+		}
+	}
+	return queryToken{}, fmt.Errorf("unterminated string at position %d", start)
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+type querySelection struct {
+	name     string
+	alias    string
+	spread   string
+	children []querySelection
+}
+
+type queryOperation struct {
+	kind       OperationType
+	name       string
+	selections []querySelection
+}
+
+type queryParser struct {
+	lexer     *queryLexer
+	token     queryToken
+	fragments map[string][]querySelection
+	// expanded counts selections visited by flatten
+	expanded int
+}
+
+func (p *queryParser) next() error {
+	token, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.token = token
+	return nil
+}
+
+func (p *queryParser) is(kind tokenKind, value string) bool {
+	return p.token.kind == kind && p.token.value == value
+}
+
+func (p *queryParser) expect(kind tokenKind, value string) error {
+	if !p.is(kind, value) {
+		return fmt.Errorf("expected %q, got %q", value, p.token.value)
+	}
+	return p.next()
+}
+
+func (p *queryParser) expectName() (string, error) {
+	if p.token.kind != tokenName {
+		return "", fmt.Errorf("expected name, got %q", p.token.value)
+	}
+	name := p.token.value
+	return name, p.next()
+}
+
+// parseDefinition parses an operation or a fragment definition.
+// Fragments are stored in the parser and nil is returned
+func (p *queryParser) parseDefinition() (*queryOperation, error) {
+	if p.is(tokenPunctuator, "{") {
+		selections, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		return &queryOperation{kind: OperationQuery, selections: selections}, nil
+	}
+
+	keyword, err := p.expectName()
+	if err != nil {
+		return nil, err
+	}
+
+	switch keyword {
+	case "fragment":
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenName, "on"); err != nil {
+			return nil, err
+		}
+		if _, err := p.expectName(); err != nil {
+			return nil, err
+		}
+		if err := p.skipDirectives(); err != nil {
+			return nil, err
+		}
+		selections, err := p.parseSelectionSet()
+		if err != nil {
+			return nil, err
+		}
+		p.fragments[name] = selections
+		return nil, nil
+	case string(OperationQuery), string(OperationMutation), string(OperationSubscription):
+		operation := &queryOperation{kind: OperationType(keyword)}
+		if p.token.kind == tokenName {
+			operation.name = p.token.value
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		if p.is(tokenPunctuator, "(") {
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.skipDirectives(); err != nil {
+			return nil, err
+		}
+		if operation.selections, err = p.parseSelectionSet(); err != nil {
+			return nil, err
+		}
+		return operation, nil
+	default:
+		return nil, fmt.Errorf("unexpected definition %q", keyword)
+	}
+}
+
+func (p *queryParser) parseSelectionSet() ([]querySelection, error) {
+	if err := p.expect(tokenPunctuator, "{"); err != nil {
+		return nil, err
+	}
+
+	var selections []querySelection
+	for !p.is(tokenPunctuator, "}") {
+		if p.token.kind == tokenEOF {
+			return nil, fmt.Errorf("unexpected end of the query")
+		}
+		selection, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+
+	return selections, p.next()
+}
+
+func (p *queryParser) parseSelection() (querySelection, error) {
+	var selection querySelection
+	var err error
+	if p.is(tokenPunctuator, "...") {
+		if err := p.next(); err != nil {
+			return selection, err
+		}
+		// fragment spread
+		if p.token.kind == tokenName && p.token.value != "on" {
+			selection.spread = p.token.value
+			if err := p.next(); err != nil {
+				return selection, err
+			}
+			return selection, p.skipDirectives()
+		}
+		// inline fragment
+		if p.is(tokenName, "on") {
+			if err := p.next(); err != nil {
+				return selection, err
+			}
+			if _, err := p.expectName(); err != nil {
+				return selection, err
+			}
+		}
+		if err := p.skipDirectives(); err != nil {
+			return selection, err
+		}
+		selection.children, err = p.parseSelectionSet()
+		return selection, err
+	}
+
+	if selection.name, err = p.expectName(); err != nil {
+		return selection, err
+	}
+	if p.is(tokenPunctuator, ":") {
+		if err := p.next(); err != nil {
+			return selection, err
+		}
+		selection.alias = selection.name
+		if selection.name, err = p.expectName(); err != nil {
+			return selection, err
+		}
+	}
+	if p.is(tokenPunctuator, "(") {
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return selection, err
+		}
+	}
+	if err := p.skipDirectives(); err != nil {
+		return selection, err
+	}
+	if p.is(tokenPunctuator, "{") {
+		selection.children, err = p.parseSelectionSet()
+	}
+
+	return selection, err
+}
+
+func (p *queryParser) skipDirectives() error {
+	for p.is(tokenPunctuator, "@") {
+		if err := p.next(); err != nil {
+			return err
+		}
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+		if p.is(tokenPunctuator, "(") {
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *queryParser) skipBalanced(open string, close string) error {
+	depth := 0
+	for {
+		switch {
+		case p.token.kind == tokenEOF:
+			return fmt.Errorf("expected %q, got end of the query", close)
+		case p.is(tokenPunctuator, open):
+			depth++
+		case p.is(tokenPunctuator, close):
+			depth--
+		}
+		if err := p.next(); err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *queryParser) checkExpanded() error {
+	if p.expanded > maxExpandedSelections {
+		return fmt.Errorf("the query expands more than %d selections", maxExpandedSelections)
+	}
+	return nil
+}
+
+// flatten resolves fragment spreads and inline fragments into fields.
+// The expansion stops once the number of visited selections exceeds the limit
+func (p *queryParser) flatten(selections []querySelection, depth int) []querySelection {
+	var results []querySelection
+	if depth > maxFragmentDepth {
+		return results
+	}
+
+	seen := make(map[string]int)
+	for _, selection := range selections {
+		p.expanded++
+		if p.expanded > maxExpandedSelections {
+			return results
+		}
+		var fields []querySelection
+		switch {
+		case selection.spread != "":
+			fields = p.flatten(p.fragments[selection.spread], depth+1)
+		case selection.name == "":
+			fields = p.flatten(selection.children, depth+1)
+		default:
+			selection.children = p.flatten(selection.children, depth+1)
+			fields = []querySelection{selection}
+		}
+
+		for _, field := range fields {
+			key := field.alias
+			if key == "" {
+				key = field.name
+			}
+			if i, ok := seen[key]; ok {
+				results[i].children = append(results[i].children, field.children...)
+				continue
+			}
+			seen[key] = len(results)
+			results = append(results, field)
+		}
+	}
+
+	return results
+}
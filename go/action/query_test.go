@@ -0,0 +1,128 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOperation(t *testing.T) {
+	fixtures := []struct {
+		Name      string
+		Query     string
+		FieldName string
+		Expected  *Operation
+	}{
+		{
+			Name:      "anonymous",
+			Query:     `{ goHello(args: { message: "hello {" }) { message } }`,
+			FieldName: "goHello",
+			Expected: &Operation{
+				Type:           OperationQuery,
+				FieldName:      "goHello",
+				SelectedFields: []string{"message"},
+			},
+		},
+		{
+			Name: "fragments",
+			Query: `
+# comment
+mutation CreateUser($name: String!, $withPosts: Boolean = false) {
+  other { id }
+  user: createUser(name: $name) @cached(ttl: 10) {
+    id
+    ...UserFields
+    ... on User { email }
+    posts @include(if: $withPosts) { title }
+  }
+}
+
+fragment UserFields on User {
+  name
+  posts { id }
+}`,
+			FieldName: "createUser",
+			Expected: &Operation{
+				Type:           OperationMutation,
+				Name:           "CreateUser",
+				FieldName:      "createUser",
+				Alias:          "user",
+				SelectedFields: []string{"id", "name", "posts", "posts.id", "posts.title", "email"},
+			},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			operation, err := ParseOperation(fixture.Query, fixture.FieldName)
+			assert.NoError(t, err)
+			assert.Equal(t, fixture.Expected, operation)
+		})
+	}
+
+	_, err := ParseOperation(`query { foo(`, "foo")
+	assert.Error(t, err)
+}
+
+func TestParseOperationNestedFragments(t *testing.T) {
+	query := `
+query GetUser {
+  user {
+    ...UserFields
+  }
+}
+
+fragment UserFields on User {
+  id
+  ...ProfileFields
+  posts { ...PostFields }
+}
+
+fragment ProfileFields on User {
+  ... on User { profile { bio } }
+}
+
+fragment PostFields on Post {
+  title
+  author { ...UserFields }
+}`
+
+	operation, err := ParseOperation(query, "user")
+	assert.NoError(t, err)
+	assert.Equal(t, "user", operation.FieldName)
+	for _, path := range []string{"id", "profile", "profile.bio", "posts", "posts.title", "posts.author.id", "posts.author.posts.title"} {
+		assert.True(t, operation.IsSelected(path), path)
+	}
+	assert.False(t, operation.IsSelected("email"))
+}
+
+func TestParseOperationExpansionLimit(t *testing.T) {
+	// every fragment spreads the previous one twice, that expands 2^30 selections without the limit
+	var sb strings.Builder
+	sb.WriteString("query { user { ...F30 } }\nfragment F0 on User { id }\n")
+	for i := 1; i <= 30; i++ {
+		fmt.Fprintf(&sb, "fragment F%d on User { a: user { ...F%d } b: user { ...F%d } }\n", i, i-1, i-1)
+	}
+
+	_, err := ParseOperation(sb.String(), "user")
+	assert.EqualError(t, err, fmt.Sprintf("the query expands more than %d selections", maxExpandedSelections))
+}
+
+func TestIsFieldSelected(t *testing.T) {
+	ctx := &Context{
+		ActionName:   "getUser",
+		RequestQuery: `query { getUser { id posts { title } } }`,
+	}
+	assert.True(t, ctx.IsFieldSelected("id"))
+	assert.True(t, ctx.IsFieldSelected("posts.title"))
+	assert.False(t, ctx.IsFieldSelected("email"))
+
+	// fields are assumed to be selected if the query can't be parsed
+	ctx = &Context{
+		ActionName:   "getUser",
+		RequestQuery: `query { getUser {`,
+	}
+	assert.True(t, ctx.IsFieldSelected("email"))
+}
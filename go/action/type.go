@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/hgiasac/hasura-router/go/middleware"
 	"github.com/hgiasac/hasura-router/go/tracing"
 	"github.com/hgiasac/hasura-router/go/types"
 )
@@ -12,16 +13,20 @@ import (
 // ActionName represents an extensible action name in the actions map.
 type ActionName string
 
-// action request body
-type bodyAction struct {
+// ActionInfo represents the action information of the webhook payload
+type ActionInfo struct {
 	Name string `json:"name"`
 }
 
-type actionBody struct {
-	Action           bodyAction        `json:"action"`
+// Payload represents the action webhook payload
+type Payload struct {
+	Action           ActionInfo        `json:"action"`
+	ActionID         string            `json:"action_id,omitempty"`
 	Input            json.RawMessage   `json:"input"` // This can be serialized into appropriate input type
 	SessionVariables map[string]string `json:"session_variables"`
 	RequestQuery     string            `json:"request_query"`
+	// Raw is the original request body that can be decoded for extra fields
+	Raw json.RawMessage `json:"-"`
 }
 
 // Context represents an extensible action context in the actions map.
@@ -31,6 +36,78 @@ type Context struct {
 	SessionVariables types.SessionVariables
 	Tracing          *tracing.Tracing
 	RequestQuery     string
+	ActionName       ActionName
+	ActionID         string
+	Payload          Payload
+
+	operation      *Operation
+	operationError error
+	// enqueue pushes the handler body of an asynchronous action to the worker pool
+	enqueue func(ctx *Context, handler func(ctx *Context) (interface{}, error)) (interface{}, error)
+}
+
+// Dispatch executes the handler body. The body of an asynchronous action is pushed to the worker pool instead,
+// and the acknowledgement is returned. Actions call it after the input is decoded and validated
+// so invalid requests are rejected before they are acknowledged. Typed actions call it automatically
+func (ctx *Context) Dispatch(handler func(ctx *Context) (interface{}, error)) (interface{}, error) {
+	enqueue := ctx.enqueue
+	if enqueue == nil {
+		return handler(ctx)
+	}
+	ctx.enqueue = nil
+	return enqueue(ctx, handler)
+}
+
+// Operation returns the parsed GraphQL operation of the request query.
+// The result is cached after the first call
+func (ctx *Context) Operation() (*Operation, error) {
+	if ctx.operation == nil && ctx.operationError == nil {
+		ctx.operation, ctx.operationError = ParseOperation(ctx.RequestQuery, string(ctx.ActionName))
+	}
+	return ctx.operation, ctx.operationError
+}
+
+// IsFieldSelected checks if the dot-separated output field path is selected in the request query.
+// It returns true if the request query can't be parsed so handlers still compute the field
+func (ctx *Context) IsFieldSelected(path string) bool {
+	operation, err := ctx.Operation()
+	if err != nil {
+		return true
+	}
+	return operation.IsSelected(path)
+}
+
+var _ middleware.Context = (*Context)(nil)
+
+// RouterType returns the action router type
+func (ctx *Context) RouterType() string {
+	return types.RouterTypeAction
+}
+
+// HandlerName returns the action name
+func (ctx *Context) HandlerName() string {
+	return string(ctx.ActionName)
+}
+
+// GetHeaders returns the request headers
+func (ctx *Context) GetHeaders() http.Header {
+	return ctx.Headers
+}
+
+// GetSessionVariables returns the session variables of the action
+func (ctx *Context) GetSessionVariables() types.SessionVariables {
+	return ctx.SessionVariables
+}
+
+// GetTracing returns the tracing instance
+func (ctx *Context) GetTracing() *tracing.Tracing {
+	return ctx.Tracing
+}
+
+// WithValue attaches a key-value pair to the embedded request context
+func (ctx *Context) WithValue(key interface{}, value interface{}) *Context {
+	ctx.Context = context.WithValue(ctx.Context, key, value)
+	return ctx
 }
 
 // Action represents the action to be executed.
@@ -0,0 +1,72 @@
+package action
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/hgiasac/hasura-router/go/validation"
+)
+
+// TypedAction represents an action handler with strongly typed input and output.
+type TypedAction[Input any, Output any] func(ctx *Context, input Input) (Output, error)
+
+// NewTypedAction creates a generic Action from a typed handler.
+// The action input is decoded into the Input type and validated by validate struct tags before the handler is executed.
+// It panics if validate tags of the Input type are invalid, see validation.Check
+func NewTypedAction[Input any, Output any](handler func(ctx *Context, input Input) (Output, error)) Action {
+	return TypedAction[Input, Output](handler).Action()
+}
+
+// Action converts the typed handler to the generic Action that can be registered to the router.
+// It panics if validate tags of the Input type are invalid
+func (ta TypedAction[Input, Output]) Action() Action {
+	if err := validation.Check(ta.InputType()); err != nil {
+		panic(err)
+	}
+
+	return func(ctx *Context, rawBody []byte) (interface{}, error) {
+		input, err := decodeInput[Input](rawBody)
+		if err != nil {
+			return nil, err
+		}
+
+		endValidate := func() {}
+		if ctx.Tracing != nil {
+			endValidate = ctx.Tracing.StartPhase(tracing.PhaseValidate)
+		}
+		err = validation.Validate(input)
+		endValidate()
+		if err != nil {
+			return nil, err
+		}
+
+		return ctx.Dispatch(func(ctx *Context) (interface{}, error) {
+			return ta(ctx, input)
+		})
+	}
+}
+
+// InputType returns the reflection type of the action input
+func (ta TypedAction[Input, Output]) InputType() reflect.Type {
+	return reflect.TypeOf((*Input)(nil)).Elem()
+}
+
+// OutputType returns the reflection type of the action output
+func (ta TypedAction[Input, Output]) OutputType() reflect.Type {
+	return reflect.TypeOf((*Output)(nil)).Elem()
+}
+
+func decodeInput[Input any](rawBody []byte) (Input, error) {
+	var input Input
+	if len(rawBody) == 0 {
+		return input, nil
+	}
+
+	if err := json.Unmarshal(rawBody, &input); err != nil {
+		return input, types.NewDecodeError(err)
+	}
+
+	return input, nil
+}
@@ -0,0 +1,115 @@
+package action
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type helloInput struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+type helloOutput struct {
+	Message string `json:"message"`
+}
+
+func TestTypedAction(t *testing.T) {
+	hello := TypedAction[helloInput, helloOutput](func(ctx *Context, input helloInput) (helloOutput, error) {
+		return helloOutput{Message: "hello " + input.Name}, nil
+	})
+
+	assert.Equal(t, "helloInput", hello.InputType().Name())
+	assert.Equal(t, "helloOutput", hello.OutputType().Name())
+
+	router, err := New(map[ActionName]Action{
+		"hello": hello.Action(),
+	})
+	assert.NoError(t, err)
+
+	fixtures := []struct {
+		Name       string
+		Input      string
+		StatusCode int
+		Response   map[string]interface{}
+	}{
+		{
+			Name:       "success",
+			Input:      `{"name": "foo", "age": 1}`,
+			StatusCode: http.StatusOK,
+			Response:   map[string]interface{}{"message": "hello foo"},
+		},
+		{
+			Name:       "invalid_field",
+			Input:      `{"name": "foo", "age": "1"}`,
+			StatusCode: http.StatusBadRequest,
+			Response: map[string]interface{}{
+				"message": "age: expected int, got string",
+				"extensions": map[string]interface{}{
+					"code":     types.ErrCodeBadRequest,
+					"expected": "int",
+					"path":     "age",
+				},
+			},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newTestRequest("hello", fixture.Input))
+
+			var response map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+			assert.Equal(t, fixture.Response, response)
+		})
+	}
+}
+
+type signUpInput struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"min=8"`
+}
+
+func TestTypedActionValidation(t *testing.T) {
+	router, err := New(map[ActionName]Action{
+		"signUp": NewTypedAction(func(ctx *Context, input signUpInput) (bool, error) {
+			return true, nil
+		}),
+	})
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("signUp", `{"email": "foo", "password": "123"}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp types.ActionErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "validation failed: email must be a valid email address; password must have at least 8 characters", resp.Message)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"path": "email", "rule": "email", "message": "email must be a valid email address"},
+		map[string]interface{}{"path": "password", "rule": "min", "param": "8", "message": "password must have at least 8 characters"},
+	}, resp.Extensions["violations"])
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("signUp", `{"email": "foo@example.com", "password": "12345678"}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+type invalidTagInput struct {
+	Email string `json:"email" validate:"required,unknown_rule"`
+}
+
+func TestTypedActionInvalidTags(t *testing.T) {
+	assert.PanicsWithError(t, "validation: field Email of action.invalidTagInput: unknown rule unknown_rule", func() {
+		NewTypedAction(func(ctx *Context, input invalidTagInput) (bool, error) {
+			return true, nil
+		})
+	})
+}
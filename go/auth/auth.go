@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+const (
+	DefaultSecretHeader    = "x-webhook-secret"
+	DefaultSignatureHeader = "x-webhook-signature"
+	DefaultTimestampHeader = "x-webhook-timestamp"
+	DefaultTolerance       = 5 * time.Minute
+
+	signaturePrefix = "sha256="
+)
+
+// Authenticator authenticates incoming webhook requests before the body is decoded
+type Authenticator interface {
+	Authenticate(r *http.Request, body []byte) error
+}
+
+// AuthenticatorFunc is an adapter to allow the use of ordinary functions as authenticators
+type AuthenticatorFunc func(r *http.Request, body []byte) error
+
+// Authenticate calls f(r, body)
+func (f AuthenticatorFunc) Authenticate(r *http.Request, body []byte) error {
+	return f(r, body)
+}
+
+// SharedSecret authenticates requests by a static secret header.
+// Multiple secrets are accepted at the same time to support secret rotation
+type SharedSecret struct {
+	header  string
+	secrets [][]byte
+}
+
+// NewSharedSecret creates a shared secret authenticator.
+// The secret is read from the x-webhook-secret header by default
+func NewSharedSecret(secrets ...string) *SharedSecret {
+	return &SharedSecret{
+		header:  DefaultSecretHeader,
+		secrets: toBytes(secrets),
+	}
+}
+
+// WithHeader set the header name that contains the secret
+func (ss *SharedSecret) WithHeader(header string) *SharedSecret {
+	ss.header = header
+	return ss
+}
+
+// Authenticate implements the Authenticator interface
+func (ss *SharedSecret) Authenticate(r *http.Request, body []byte) error {
+	value := r.Header.Get(ss.header)
+	if value == "" {
+		return unauthorized("missing %s header", ss.header)
+	}
+
+	for _, secret := range ss.secrets {
+		if subtle.ConstantTimeCompare([]byte(value), secret) == 1 {
+			return nil
+		}
+	}
+
+	return unauthorized("invalid %s header", ss.header)
+}
+
+// HMACSignature authenticates requests by the HMAC-SHA256 signature of the timestamp and body.
+// Requests whose timestamp is out of the tolerance window are rejected to prevent replay attacks
+type HMACSignature struct {
+	signatureHeader string
+	timestampHeader string
+	secrets         [][]byte
+	tolerance       time.Duration
+	now             func() time.Time
+}
+
+// NewHMACSignature creates a HMAC signature authenticator.
+// Multiple secrets are accepted at the same time to support secret rotation
+func NewHMACSignature(secrets ...string) *HMACSignature {
+	return &HMACSignature{
+		signatureHeader: DefaultSignatureHeader,
+		timestampHeader: DefaultTimestampHeader,
+		secrets:         toBytes(secrets),
+		tolerance:       DefaultTolerance,
+		now:             time.Now,
+	}
+}
+
+// WithHeaders set the header names that contain the signature and the unix timestamp
+func (hs *HMACSignature) WithHeaders(signatureHeader string, timestampHeader string) *HMACSignature {
+	hs.signatureHeader = signatureHeader
+	hs.timestampHeader = timestampHeader
+	return hs
+}
+
+// WithTolerance set the maximum allowed difference between the request timestamp and the current time
+func (hs *HMACSignature) WithTolerance(tolerance time.Duration) *HMACSignature {
+	hs.tolerance = tolerance
+	return hs
+}
+
+// Authenticate implements the Authenticator interface
+func (hs *HMACSignature) Authenticate(r *http.Request, body []byte) error {
+	signature := strings.TrimPrefix(r.Header.Get(hs.signatureHeader), signaturePrefix)
+	if signature == "" {
+		return unauthorized("missing %s header", hs.signatureHeader)
+	}
+	timestamp := r.Header.Get(hs.timestampHeader)
+	if timestamp == "" {
+		return unauthorized("missing %s header", hs.timestampHeader)
+	}
+
+	unixTime, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return unauthorized("invalid %s header", hs.timestampHeader)
+	}
+	if diff := hs.now().Sub(time.Unix(unixTime, 0)); diff > hs.tolerance || diff < -hs.tolerance {
+		return unauthorized("the request timestamp is out of the tolerance window")
+	}
+
+	signatureBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return unauthorized("invalid %s header", hs.signatureHeader)
+	}
+
+	for _, secret := range hs.secrets {
+		if hmac.Equal(signatureBytes, computeSignature(secret, timestamp, body)) {
+			return nil
+		}
+	}
+
+	return unauthorized("invalid %s header", hs.signatureHeader)
+}
+
+// Sign computes the signature header value of the body at the timestamp.
+// It returns the signature and the timestamp header values
+func Sign(secret string, timestamp time.Time, body []byte) (string, string) {
+	unixTime := strconv.FormatInt(timestamp.Unix(), 10)
+	return signaturePrefix + hex.EncodeToString(computeSignature([]byte(secret), unixTime, body)), unixTime
+}
+
+// Any creates an authenticator that succeeds if any of the authenticators succeeds
+func Any(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(r *http.Request, body []byte) error {
+		var err error
+		for _, authenticator := range authenticators {
+			if err = authenticator.Authenticate(r, body); err == nil {
+				return nil
+			}
+		}
+		if err == nil {
+			return unauthorized("no authenticator is configured")
+		}
+		return err
+	})
+}
+
+func computeSignature(secret []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+func toBytes(values []string) [][]byte {
+	results := make([][]byte, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			results = append(results, []byte(v))
+		}
+	}
+	return results
+}
+
+func unauthorized(format string, args ...interface{}) error {
+	return types.NewError(types.ErrCodeUnauthorized, fmt.Sprintf(format, args...))
+}
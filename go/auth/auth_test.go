@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func assertUnauthorized(t *testing.T, err error) {
+	t.Helper()
+	var authErr types.Error
+	if !errors.As(err, &authErr) {
+		t.Fatalf("expected unauthorized error, got: %v", err)
+	}
+	assert.Equal(t, types.ErrCodeUnauthorized, authErr.Code)
+}
+
+func TestSharedSecret(t *testing.T) {
+	authenticator := NewSharedSecret("old", "new").WithHeader("x-secret")
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	assertUnauthorized(t, authenticator.Authenticate(r, nil))
+
+	for _, secret := range []string{"old", "new"} {
+		r.Header.Set("x-secret", secret)
+		assert.NoError(t, authenticator.Authenticate(r, nil))
+	}
+
+	r.Header.Set("x-secret", "foo")
+	assertUnauthorized(t, authenticator.Authenticate(r, nil))
+}
+
+func TestHMACSignature(t *testing.T) {
+	now := time.Now()
+	body := []byte(`{"foo":"bar"}`)
+	authenticator := NewHMACSignature("secret").WithTolerance(time.Minute)
+
+	newRequest := func(secret string, timestamp time.Time, body []byte) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		signature, unixTime := Sign(secret, timestamp, body)
+		r.Header.Set(DefaultSignatureHeader, signature)
+		r.Header.Set(DefaultTimestampHeader, unixTime)
+		return r
+	}
+
+	assert.NoError(t, authenticator.Authenticate(newRequest("secret", now, body), body))
+	assertUnauthorized(t, authenticator.Authenticate(newRequest("foo", now, body), body))
+	assertUnauthorized(t, authenticator.Authenticate(newRequest("secret", now, body), []byte(`{}`)))
+	assertUnauthorized(t, authenticator.Authenticate(newRequest("secret", now.Add(-2*time.Minute), body), body))
+
+	r := newRequest("secret", now, body)
+	r.Header.Set(DefaultTimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+	assertUnauthorized(t, authenticator.Authenticate(r, body))
+}
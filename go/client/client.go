@@ -0,0 +1,275 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// Request represents a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// GraphQLError represents an error of the GraphQL response
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// Response represents a GraphQL response
+type Response struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Errors []GraphQLError  `json:"errors,omitempty"`
+}
+
+type sessionMode int
+
+const (
+	modeDefault sessionMode = iota
+	modeAdmin
+	modeSession
+)
+
+// Client executes GraphQL operations against Hasura
+type Client struct {
+	endpoint         string
+	adminSecret      string
+	httpClient       *http.Client
+	headers          http.Header
+	mode             sessionMode
+	sessionVariables types.SessionVariables
+	backendOnly      bool
+}
+
+// New creates a Hasura GraphQL client with the endpoint, e.g. http://localhost:8080/v1/graphql
+func New(endpoint string) *Client {
+	return &Client{
+		endpoint:   endpoint,
+		httpClient: http.DefaultClient,
+		headers:    http.Header{},
+	}
+}
+
+// WithAdminSecret set the admin secret that is used by the admin mode and session impersonation
+func (c *Client) WithAdminSecret(adminSecret string) *Client {
+	c.adminSecret = adminSecret
+	return c
+}
+
+// WithHTTPClient set the http client that sends requests
+func (c *Client) WithHTTPClient(httpClient *http.Client) *Client {
+	c.httpClient = httpClient
+	return c
+}
+
+// WithHeader set a static header of every request
+func (c *Client) WithHeader(name string, value string) *Client {
+	c.headers.Set(name, value)
+	return c
+}
+
+// AsAdmin returns a copy of the client that executes operations with the admin role.
+// The copy is safe to use for a single request while the original client is shared
+func (c *Client) AsAdmin() *Client {
+	result := c.clone()
+	result.mode = modeAdmin
+	return result
+}
+
+// AsSession returns a copy of the client that impersonates the session variables, usually from the handler context.
+// The admin secret is sent with session variables so Hasura applies permissions of the role.
+// The x-hasura-use-backend-only-permissions variable is forwarded if it exists
+func (c *Client) AsSession(sessionVariables types.SessionVariables) *Client {
+	result := c.clone()
+	result.mode = modeSession
+	result.sessionVariables = types.NewSessionVariables(sessionVariables)
+	return result
+}
+
+// WithBackendOnly returns a copy of the client that sets whether backend-only permissions of mutations are used
+func (c *Client) WithBackendOnly(backendOnly bool) *Client {
+	result := c.clone()
+	result.backendOnly = backendOnly
+	return result
+}
+
+func (c *Client) clone() *Client {
+	result := *c
+	result.headers = c.headers.Clone()
+	return &result
+}
+
+// Execute executes the GraphQL request and decodes the response data into the result.
+// GraphQL errors are converted to types.Error
+func (c *Client) Execute(ctx context.Context, request Request, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("failed to encode the graphql request: %s", err))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return types.NewError(types.ErrCodeInternal, err.Error())
+	}
+	c.setHeaders(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	var response Response
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		if resp.StatusCode >= http.StatusBadRequest {
+			return statusError(resp.StatusCode, respBody)
+		}
+		return types.NewError(types.ErrCodeInternal, fmt.Sprintf("failed to decode the graphql response: %s", err))
+	}
+	if len(response.Errors) > 0 {
+		return NewGraphQLError(response.Errors)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return statusError(resp.StatusCode, respBody)
+	}
+
+	if result == nil || len(response.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(response.Data, result); err != nil {
+		return types.NewDecodeError(err, "data")
+	}
+	return nil
+}
+
+// Query executes the GraphQL query
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, result interface{}) error {
+	return c.Execute(ctx, Request{Query: query, Variables: variables}, result)
+}
+
+// Mutate executes the GraphQL mutation
+func (c *Client) Mutate(ctx context.Context, mutation string, variables map[string]interface{}, result interface{}) error {
+	return c.Execute(ctx, Request{Query: mutation, Variables: variables}, result)
+}
+
+// Execute executes the GraphQL request and decodes the response data into the T type
+func Execute[T any](ctx context.Context, c *Client, request Request) (T, error) {
+	var result T
+	err := c.Execute(ctx, request, &result)
+	return result, err
+}
+
+func (c *Client) setHeaders(ctx context.Context, headers http.Header) {
+	for name, values := range c.headers {
+		headers[name] = values
+	}
+	headers.Set("Content-Type", "application/json")
+
+	switch c.mode {
+	case modeAdmin:
+		headers.Set(types.XHasuraAdminSecret, c.adminSecret)
+		headers.Set(types.XHasuraRole, types.RoleAdmin)
+	case modeSession:
+		if c.adminSecret != "" {
+			headers.Set(types.XHasuraAdminSecret, c.adminSecret)
+		}
+		for name, value := range c.sessionVariables {
+			if strings.HasPrefix(name, "x-hasura-") && name != types.XHasuraAdminSecret {
+				headers.Set(name, value)
+			}
+		}
+	}
+	if c.backendOnly {
+		headers.Set(types.XHasuraUseBackendOnlyPermissions, "true")
+	}
+
+	if tracer := tracing.FromContext(ctx); tracer != nil {
+		headers.Set(types.XRequestId, tracer.GetRequestId())
+	}
+	tracing.InjectSpanContext(headers, tracing.ParentSpanContext(ctx))
+}
+
+// graphQLErrorCodes maps Hasura GraphQL error codes to router error codes
+var graphQLErrorCodes = map[string]string{
+	"access-denied":     types.ErrCodeUnauthorized,
+	"invalid-jwt":       types.ErrCodeUnauthorized,
+	"validation-failed": types.ErrCodeBadRequest,
+}
+
+// NewGraphQLError converts GraphQL errors to types.Error.
+// The code, message and extensions are taken from the first error, and all errors are listed in the errors extension.
+// Known Hasura error codes are mapped to router error codes, and the original code is kept in the hasura_code extension
+func NewGraphQLError(errs []GraphQLError) types.Error {
+	if len(errs) == 0 {
+		return types.NewError(types.ErrCodeUnknown, "unknown graphql error")
+	}
+
+	first := errs[0]
+	hasuraCode, _ := first.Extensions["code"].(string)
+	code, ok := graphQLErrorCodes[hasuraCode]
+	if !ok {
+		code = hasuraCode
+	}
+	if code == "" {
+		code = types.ErrCodeUnknown
+	}
+
+	result := types.NewError(code, first.Message)
+	for key, value := range first.Extensions {
+		if key != "code" {
+			result.Extensions[key] = value
+		}
+	}
+	if ok {
+		result.Extensions["hasura_code"] = hasuraCode
+	}
+	if first.Path != nil {
+		result.Extensions["response_path"] = first.Path
+	}
+	if len(errs) > 1 {
+		result.Extensions["errors"] = errs
+	}
+	return result
+}
+
+func statusError(statusCode int, body []byte) types.Error {
+	code := types.ErrCodeInternal
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		code = types.ErrCodeUnauthorized
+	case statusCode == http.StatusNotFound:
+		code = types.ErrCodeNotFound
+	case statusCode == http.StatusServiceUnavailable || statusCode == http.StatusBadGateway:
+		code = types.ErrCodeUnavailable
+	case statusCode == http.StatusGatewayTimeout:
+		code = types.ErrCodeTimeout
+	}
+	return types.NewError(code, fmt.Sprintf("hasura responded with status %d: %s", statusCode, strings.TrimSpace(string(body))))
+}
+
+func contextError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return types.NewError(types.ErrCodeTimeout, fmt.Sprintf("the graphql request exceeded the deadline: %s", err))
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("the graphql request was canceled: %w", ctx.Err())
+	}
+	return types.NewError(types.ErrCodeUnavailable, fmt.Sprintf("failed to send the graphql request: %s", err))
+}
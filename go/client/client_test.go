@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type usersResponse struct {
+	Users []user `json:"users"`
+}
+
+func TestClientSession(t *testing.T) {
+	var headers http.Header
+	var request Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		w.Write([]byte(`{"data": {"users": [{"id": 1, "name": "foo"}]}}`))
+	}))
+	defer server.Close()
+
+	client := New(server.URL).WithAdminSecret("secret").WithHeader("x-client", "test")
+	tracer := tracing.New("request-1")
+	ctx := tracing.NewContext(context.Background(), tracer)
+
+	fixtures := []struct {
+		Name     string
+		Client   *Client
+		Expected map[string]string
+	}{
+		{
+			Name:   "default",
+			Client: client,
+			Expected: map[string]string{
+				types.XHasuraAdminSecret: "",
+				types.XHasuraRole:        "",
+				"x-client":               "test",
+				types.XRequestId:         "request-1",
+			},
+		},
+		{
+			Name:   "admin",
+			Client: client.AsAdmin(),
+			Expected: map[string]string{
+				types.XHasuraAdminSecret: "secret",
+				types.XHasuraRole:        "admin",
+			},
+		},
+		{
+			Name: "session",
+			Client: client.AsSession(types.SessionVariables{
+				"X-Hasura-Role":                        "user",
+				"x-hasura-user-id":                     "1",
+				types.XHasuraUseBackendOnlyPermissions: "true",
+			}),
+			Expected: map[string]string{
+				types.XHasuraAdminSecret:               "secret",
+				types.XHasuraRole:                      "user",
+				"x-hasura-user-id":                     "1",
+				types.XHasuraUseBackendOnlyPermissions: "true",
+			},
+		},
+		{
+			Name:   "backend_only",
+			Client: client.AsSession(types.SessionVariables{"x-hasura-role": "service"}).WithBackendOnly(true),
+			Expected: map[string]string{
+				types.XHasuraRole:                      "service",
+				types.XHasuraUseBackendOnlyPermissions: "true",
+			},
+		},
+	}
+
+	for _, fixture := range fixtures {
+		result, err := Execute[usersResponse](ctx, fixture.Client, Request{
+			Query:     "query GetUsers($limit: Int!) { users(limit: $limit) { id name } }",
+			Variables: map[string]interface{}{"limit": 1},
+		})
+		assert.NoError(t, err, fixture.Name)
+		assert.Equal(t, []user{{ID: 1, Name: "foo"}}, result.Users, fixture.Name)
+		assert.Equal(t, float64(1), request.Variables["limit"], fixture.Name)
+		for name, value := range fixture.Expected {
+			assert.Equal(t, value, headers.Get(name), fixture.Name+": "+name)
+		}
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graphql_error":
+			w.Write([]byte(`{"errors": [{"message": "field 'foo' not found in type: 'query_root'", "extensions": {"path": "$.selectionSet.foo", "code": "validation-failed"}}]}`))
+		case "/unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`invalid x-hasura-admin-secret/x-hasura-access-key`))
+		case "/slow":
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	err := New(server.URL+"/graphql_error").Query(context.Background(), "query { foo }", nil, nil)
+	assert.Equal(t, types.Error{
+		Code:    types.ErrCodeBadRequest,
+		Message: "field 'foo' not found in type: 'query_root'",
+		Extensions: map[string]interface{}{
+			"code":        types.ErrCodeBadRequest,
+			"hasura_code": "validation-failed",
+			"path":        "$.selectionSet.foo",
+		},
+	}, err)
+
+	err = New(server.URL+"/unauthorized").Mutate(context.Background(), "mutation { foo }", nil, nil)
+	assert.Equal(t, types.ErrCodeUnauthorized, types.ToError(err).Code)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = New(server.URL+"/slow").Query(ctx, "query { foo }", nil, nil)
+	assert.Equal(t, types.ErrCodeTimeout, types.ToError(err).Code)
+}
+
+func TestNewGraphQLError(t *testing.T) {
+	fixtures := []struct {
+		HasuraCode string
+		Code       string
+	}{
+		{"access-denied", types.ErrCodeUnauthorized},
+		{"invalid-jwt", types.ErrCodeUnauthorized},
+		{"validation-failed", types.ErrCodeBadRequest},
+		{"constraint-violation", "constraint-violation"},
+		{"", types.ErrCodeUnknown},
+	}
+
+	for _, fixture := range fixtures {
+		err := NewGraphQLError([]GraphQLError{{
+			Message:    "failed",
+			Extensions: map[string]interface{}{"code": fixture.HasuraCode},
+		}})
+		assert.Equal(t, fixture.Code, err.Code, fixture.HasuraCode)
+		assert.Equal(t, fixture.Code, err.Extensions["code"], fixture.HasuraCode)
+	}
+}
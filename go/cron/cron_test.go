@@ -0,0 +1,323 @@
+package cron
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/idempotency"
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRequest(id string, name string, scheduledTime time.Time) *http.Request {
+	body, _ := json.Marshal(EventPayload{
+		ID:            id,
+		Name:          name,
+		ScheduledTime: scheduledTime,
+		Payload:       json.RawMessage(`{}`),
+	})
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func TestCronPolicy(t *testing.T) {
+	var lateness []time.Duration
+	router := New(map[string]Handler{
+		"report": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			lateness = append(lateness, ctx.Lateness)
+			return "ok", nil
+		},
+	}).WithPolicy("report", Policy{
+		MaxLateness:      time.Hour,
+		CoalesceInterval: time.Minute,
+	})
+
+	now := time.Now()
+	fixtures := []struct {
+		Name          string
+		ScheduledTime time.Time
+		Skipped       bool
+	}{
+		{"too_late", now.Add(-2 * time.Hour), true},
+		{"newer_run_due", now.Add(-2 * time.Minute), true},
+		{"latest", now.Add(-30 * time.Second), false},
+		{"older_than_processed", now.Add(-40 * time.Second), true},
+	}
+
+	for _, fixture := range fixtures {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newTestRequest(fixture.Name, "report", fixture.ScheduledTime))
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp SkippedResponse
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, fixture.Skipped, resp.Skipped, fixture.Name)
+	}
+
+	assert.Len(t, lateness, 1)
+	assert.GreaterOrEqual(t, lateness[0], 30*time.Second)
+}
+
+func TestCronPolicyRetry(t *testing.T) {
+	var calls int
+	router := New(map[string]Handler{
+		"report": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, types.NewError(types.ErrCodeInternal, "failed to send the report")
+			}
+			return "ok", nil
+		},
+	}).WithPolicy("report", Policy{CoalesceInterval: time.Minute})
+
+	scheduledTime := time.Now().Add(-time.Second)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("1", "report", scheduledTime))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Hasura retries the failed run with the same scheduled time
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("1", "report", scheduledTime))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `"ok"`, w.Body.String())
+	assert.Equal(t, 2, calls)
+
+	// an older run is coalesced after the newer run succeeded
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("0", "report", scheduledTime.Add(-time.Second)))
+	var resp SkippedResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Skipped)
+	assert.Equal(t, 2, calls)
+}
+
+func TestOneOffScheduledEvent(t *testing.T) {
+	var comments []string
+	handler := func(ctx *Context, payload EventPayload) (interface{}, error) {
+		comments = append(comments, payload.Comment)
+		return ctx.TriggerName, nil
+	}
+	handlers := map[string]Handler{
+		"report":        handler,
+		"send_email":    handler,
+		"weekly digest": handler,
+	}
+
+	body := `{
+		"id": "c5ab16ad-7a9f-4fd0-9f4c-6b7f4d3cbdc8",
+		"scheduled_time": "2023-01-15T10:30:00Z",
+		"created_at": "2023-01-15T10:29:00.123456Z",
+		"comment": "weekly digest",
+		"webhook": "http://localhost:9001/crons",
+		"headers": [{"name": "x-tenant", "value": "acme"}],
+		"payload": {"job": {"name": "send_email"}}
+	}`
+
+	fixtures := []struct {
+		Name     string
+		RouteKey RouteKey
+		Body     string
+		Status   int
+		Response string
+	}{
+		{"by_name", RouteByName, body, http.StatusNotFound, ""},
+		{"by_comment", RouteByComment, body, http.StatusOK, `"weekly digest"`},
+		{"by_payload_field", RouteByPayloadField("job", "name"), body, http.StatusOK, `"send_email"`},
+		{"by_missing_payload_field", RouteByPayloadField("job", "id"), body, http.StatusNotFound, ""},
+		{"cron_by_name_or", RouteByNameOr(RouteByComment), `{"id": "1", "name": "report", "scheduled_time": "2023-01-15T10:30:00Z", "payload": {}}`, http.StatusOK, `"report"`},
+		{"one_off_by_name_or", RouteByNameOr(RouteByComment), body, http.StatusOK, `"weekly digest"`},
+	}
+
+	for _, fixture := range fixtures {
+		router := New(handlers).WithRouteKey(fixture.RouteKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(fixture.Body)))
+		assert.Equal(t, fixture.Status, w.Code, fixture.Name)
+		if fixture.Response != "" {
+			assert.Equal(t, fixture.Response, w.Body.String(), fixture.Name)
+		}
+	}
+	assert.Equal(t, []string{"weekly digest", "weekly digest", "", "weekly digest"}, comments)
+
+	var payload EventPayload
+	assert.NoError(t, json.Unmarshal([]byte(body), &payload))
+	assert.True(t, payload.IsOneOff())
+	assert.Equal(t, time.Date(2023, time.January, 15, 10, 29, 0, 123456000, time.UTC), *payload.CreatedAt)
+	assert.Equal(t, []HeaderConfig{{Name: "x-tenant", Value: "acme"}}, payload.Headers)
+}
+
+func TestCronTimeout(t *testing.T) {
+	router := New(map[string]Handler{
+		"slow": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		"fast": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			_, hasDeadline := ctx.Deadline()
+			return hasDeadline, nil
+		},
+	}).WithTimeout(time.Second).WithHandlerTimeout("slow", 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("1", "slow", time.Now()))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, types.ErrCodeTimeout, resp.Code)
+	assert.Equal(t, "cron trigger slow exceeded the timeout of 10ms", resp.Message)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("2", "fast", time.Now()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Body.String())
+}
+
+func TestCronPanic(t *testing.T) {
+	var metadata map[string]interface{}
+	router := New(map[string]Handler{
+		"panic": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			panic("something went wrong")
+		},
+	})
+	router.OnError(func(ctx *Context, err error, m map[string]interface{}) {
+		metadata = m
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("1", "panic", time.Now()))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, types.ErrCodeInternal, resp.Code)
+	assert.Equal(t, "internal server error", resp.Message)
+	assert.Equal(t, "something went wrong", metadata["panic"])
+	assert.Contains(t, metadata["stack"], "TestCronPanic")
+
+	// repanic only takes effect in debug mode
+	router.WithRepanic(true)
+	assert.NotPanics(t, func() {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest("2", "panic", time.Now()))
+	})
+	router.WithDebug(true)
+	assert.PanicsWithValue(t, "something went wrong", func() {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest("3", "panic", time.Now()))
+	})
+}
+
+func TestCronIdempotency(t *testing.T) {
+	calls := 0
+	router := New(map[string]Handler{
+		"report": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, types.NewError(types.ErrCodeUnavailable, "try again")
+			}
+			return calls, nil
+		},
+	}).WithIdempotency(idempotency.NewMemoryStore(10))
+
+	// failed deliveries aren't recorded so the retry executes the handler
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("1", "report", time.Now()))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newTestRequest("1", "report", time.Now()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("2", "report", time.Now()))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "3", w.Body.String())
+	assert.Equal(t, 3, calls)
+}
+
+func TestCronMetrics(t *testing.T) {
+	var observations []metrics.Observation
+	router := New(map[string]Handler{
+		"report": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			return "ok", nil
+		},
+	}).WithMetrics(metrics.RecorderFunc(func(observation metrics.Observation) {
+		observations = append(observations, observation)
+	}))
+
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("1", "report", time.Now()))
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("2", "unknown", time.Now()))
+
+	assert.Len(t, observations, 2)
+	assert.Equal(t, "report", observations[0].Handler)
+	assert.Equal(t, types.RouterTypeCronTrigger, observations[0].RouterType)
+	assert.Equal(t, metrics.StatusSuccess, observations[0].Status())
+	assert.Equal(t, metrics.UnknownHandler, observations[1].Handler)
+	assert.Equal(t, types.ErrCodeNotFound, observations[1].ErrorCode)
+}
+
+func TestCronAuthentication(t *testing.T) {
+	var called bool
+	router := New(map[string]Handler{
+		"report": func(ctx *Context, payload EventPayload) (interface{}, error) {
+			called = true
+			return "ok", nil
+		},
+	}).WithAuthenticator(auth.NewSharedSecret("secret"))
+
+	// the malformed body would fail with 400 if it were decoded before authentication
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{"))))
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, types.ErrCodeUnauthorized, resp.Code)
+	assert.False(t, called)
+
+	r := newTestRequest("1", "report", time.Now())
+	r.Header.Set(auth.DefaultSecretHeader, "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+}
+
+type reportPayload struct {
+	Email string `json:"email"`
+	Days  int    `json:"days"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	router := New(map[string]Handler{
+		"report": NewTypedHandler(func(ctx *Context, event EventPayload, payload reportPayload) (string, error) {
+			return fmt.Sprintf("%s:%d", payload.Email, payload.Days), nil
+		}),
+	})
+
+	fixtures := []struct {
+		Name       string
+		Payload    string
+		StatusCode int
+		Response   string
+	}{
+		{"payload", `{"email": "foo@example.com", "days": 7}`, http.StatusOK, `"foo@example.com:7"`},
+		{"null", `null`, http.StatusOK, `":0"`},
+		{"invalid_field", `{"email": "foo@example.com", "days": "7"}`, http.StatusBadRequest, `"path":"payload.days"`},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			body := `{"id": "1", "name": "report", "scheduled_time": "2022-01-01T00:00:00Z", "payload": ` + fixture.Payload + `}`
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(body))))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+			assert.Contains(t, w.Body.String(), fixture.Response)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package cron
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Policy represents the scheduling policy of a cron trigger handler
+type Policy struct {
+	// MaxLateness skips runs delivered later than the duration after the scheduled time. Disabled if zero
+	MaxLateness time.Duration
+	// CoalesceInterval coalesces missed runs of the trigger, usually set to the cron interval.
+	// A run is skipped if it is late for at least the interval, that means a newer run is already due,
+	// or if a newer run has already been processed successfully. Disabled if zero
+	CoalesceInterval time.Duration
+}
+
+// SkippedResponse represents the response of a run skipped by the policy
+type SkippedResponse struct {
+	Skipped    bool    `json:"skipped"`
+	Reason     string  `json:"reason"`
+	LatenessMs float64 `json:"lateness_ms"`
+}
+
+// scheduleState stores the latest processed scheduled time of each trigger
+type scheduleState struct {
+	sync.Mutex
+	lastScheduled map[string]time.Time
+}
+
+// WithPolicy set the scheduling policy of the handler
+func (rt *Router) WithPolicy(name string, policy Policy) *Router {
+	rt.policies[name] = policy
+	return rt
+}
+
+// WithDefaultPolicy set the scheduling policy of handlers that don't have their own policy
+func (rt *Router) WithDefaultPolicy(policy Policy) *Router {
+	rt.defaultPolicy = policy
+	return rt
+}
+
+// checkPolicy returns the reason if the run should be skipped
+func (rt *Router) checkPolicy(name string, scheduledTime time.Time, lateness time.Duration) string {
+	policy, ok := rt.policies[name]
+	if !ok {
+		policy = rt.defaultPolicy
+	}
+
+	if policy.MaxLateness > 0 && lateness > policy.MaxLateness {
+		return fmt.Sprintf("the run is late for %s, exceeding the max lateness of %s", lateness, policy.MaxLateness)
+	}
+	if policy.CoalesceInterval <= 0 || scheduledTime.IsZero() {
+		return ""
+	}
+	if lateness >= policy.CoalesceInterval {
+		return fmt.Sprintf("the run is coalesced because it is late for %s and a newer run is due", lateness)
+	}
+
+	rt.schedules.Lock()
+	defer rt.schedules.Unlock()
+	// retries of a failed run have the same scheduled time and must be executed
+	if last, ok := rt.schedules.lastScheduled[name]; ok && scheduledTime.Before(last) {
+		return fmt.Sprintf("the run is coalesced because a newer run scheduled at %s was processed", last.Format(time.RFC3339))
+	}
+
+	return ""
+}
+
+// recordScheduled stores the scheduled time of the run after it is processed successfully
+func (rt *Router) recordScheduled(name string, scheduledTime time.Time) {
+	if scheduledTime.IsZero() {
+		return
+	}
+
+	rt.schedules.Lock()
+	defer rt.schedules.Unlock()
+	if last, ok := rt.schedules.lastScheduled[name]; !ok || scheduledTime.After(last) {
+		rt.schedules.lastScheduled[name] = scheduledTime
+	}
+}
@@ -0,0 +1,63 @@
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// RouteKey returns the handler name of the scheduled event
+type RouteKey func(payload EventPayload) string
+
+// RouteByName routes scheduled events by the cron trigger name. It is the default route key
+func RouteByName(payload EventPayload) string {
+	return payload.Name
+}
+
+// RouteByComment routes scheduled events by the comment of one-off scheduled events
+func RouteByComment(payload EventPayload) string {
+	return payload.Comment
+}
+
+// RouteByPayloadField routes scheduled events by a field in the payload, e.g. RouteByPayloadField("job", "name").
+// The field value should be a string, number or boolean
+func RouteByPayloadField(path ...string) RouteKey {
+	return func(payload EventPayload) string {
+		var value interface{}
+		if err := json.Unmarshal(payload.Payload, &value); err != nil {
+			return ""
+		}
+		for _, key := range path {
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return ""
+			}
+			value = object[key]
+		}
+
+		switch v := value.(type) {
+		case string:
+			return v
+		case float64, bool:
+			return fmt.Sprint(v)
+		default:
+			return ""
+		}
+	}
+}
+
+// RouteByNameOr routes cron triggers by the trigger name and one-off scheduled events by the fallback route key
+func RouteByNameOr(fallback RouteKey) RouteKey {
+	return func(payload EventPayload) string {
+		if !payload.IsOneOff() {
+			return payload.Name
+		}
+		return fallback(payload)
+	}
+}
+
+// WithRouteKey set the function that resolves the handler name of scheduled events.
+// Handlers, timeouts, middlewares and policies are registered by the resolved name
+func (rt *Router) WithRouteKey(routeKey RouteKey) *Router {
+	rt.routeKey = routeKey
+	return rt
+}
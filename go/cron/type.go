@@ -6,15 +6,34 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/hgiasac/hasura-router/go/middleware"
 	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
 )
 
-// EventPayload represents Hasura schedule cron payload
+// EventPayload represents Hasura scheduled event payload of cron triggers and one-off scheduled events.
+// The name is empty for one-off scheduled events
 type EventPayload struct {
 	ID            string          `json:"id"`
-	Name          string          `json:"name"`
+	Name          string          `json:"name,omitempty"`
 	ScheduledTime time.Time       `json:"scheduled_time"`
 	Payload       json.RawMessage `json:"payload"`
+	Comment       string          `json:"comment,omitempty"`
+	CreatedAt     *time.Time      `json:"created_at,omitempty"`
+	Webhook       string          `json:"webhook,omitempty"`
+	Headers       []HeaderConfig  `json:"headers,omitempty"`
+}
+
+// HeaderConfig represents a header configuration of the one-off scheduled event
+type HeaderConfig struct {
+	Name         string `json:"name"`
+	Value        string `json:"value,omitempty"`
+	ValueFromEnv string `json:"value_from_env,omitempty"`
+}
+
+// IsOneOff checks if the payload is a one-off scheduled event
+func (ep EventPayload) IsOneOff() bool {
+	return ep.Name == ""
 }
 
 // Handler represents the event handler to be executed.
@@ -23,6 +42,42 @@ type Handler func(ctx *Context, payload EventPayload) (interface{}, error)
 // Context represents an extensible event context.
 type Context struct {
 	context.Context
-	Headers http.Header
-	Tracing *tracing.Tracing
+	Headers       http.Header
+	Tracing       *tracing.Tracing
+	TriggerName   string
+	ScheduledTime time.Time
+	Lateness      time.Duration
+}
+
+var _ middleware.Context = (*Context)(nil)
+
+// RouterType returns the cron trigger router type
+func (ctx *Context) RouterType() string {
+	return types.RouterTypeCronTrigger
+}
+
+// HandlerName returns the trigger name
+func (ctx *Context) HandlerName() string {
+	return ctx.TriggerName
+}
+
+// GetHeaders returns the request headers
+func (ctx *Context) GetHeaders() http.Header {
+	return ctx.Headers
+}
+
+// GetSessionVariables returns the session variables. Cron triggers do not have session variables
+func (ctx *Context) GetSessionVariables() types.SessionVariables {
+	return types.SessionVariables{}
+}
+
+// GetTracing returns the tracing instance
+func (ctx *Context) GetTracing() *tracing.Tracing {
+	return ctx.Tracing
+}
+
+// WithValue attaches a key-value pair to the embedded request context
+func (ctx *Context) WithValue(key interface{}, value interface{}) *Context {
+	ctx.Context = context.WithValue(ctx.Context, key, value)
+	return ctx
 }
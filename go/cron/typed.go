@@ -0,0 +1,31 @@
+package cron
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// TypedHandler represents a cron handler with strongly typed payload and output.
+type TypedHandler[T any, Output any] func(ctx *Context, event EventPayload, payload T) (Output, error)
+
+// NewTypedHandler creates a generic Handler from a typed handler.
+// The cron payload is decoded into the T type before the handler is executed
+func NewTypedHandler[T any, Output any](handler func(ctx *Context, event EventPayload, payload T) (Output, error)) Handler {
+	return TypedHandler[T, Output](handler).Handler()
+}
+
+// Handler converts the typed handler to the generic Handler that can be registered to the router
+func (th TypedHandler[T, Output]) Handler() Handler {
+	return func(ctx *Context, event EventPayload) (interface{}, error) {
+		var payload T
+		if len(event.Payload) > 0 && !bytes.Equal(event.Payload, []byte("null")) {
+			if err := json.Unmarshal(event.Payload, &payload); err != nil {
+				return nil, types.NewDecodeError(err, "payload")
+			}
+		}
+
+		return th(ctx, event, payload)
+	}
+}
@@ -1,30 +1,66 @@
 package event
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
+	"time"
 
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/idempotency"
+	"github.com/hgiasac/hasura-router/go/logging"
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/middleware"
 	"github.com/hgiasac/hasura-router/go/tracing"
 	"github.com/hgiasac/hasura-router/go/types"
 )
 
 // Router represent a generic event trigger http handler
 type Router struct {
-	handlers  map[string]Handler
-	onSuccess func(ctx *Context, response interface{}, metadata map[string]interface{})
-	onError   func(ctx *Context, err error, metadata map[string]interface{})
-	debug     bool
+	handlers           map[string]Handler
+	onSuccess          func(ctx *Context, response interface{}, metadata map[string]interface{})
+	onError            func(ctx *Context, err error, metadata map[string]interface{})
+	logger             logging.Logger
+	redactor           *tracing.Redactor
+	tracer             tracing.Tracer
+	metrics            metrics.Recorder
+	debug              bool
+	repanic            bool
+	timeout            time.Duration
+	timeouts           map[string]time.Duration
+	authenticator      auth.Authenticator
+	middlewares        []middleware.Middleware
+	handlerMiddlewares map[string][]middleware.Middleware
+	idempotencyStore   idempotency.Store
+	idempotencyLocker  idempotency.Locker
+	deadLetterSink     DeadLetterSink
+	opHandlers         map[string]map[OpName]Handler
+	tableHandlers      map[EventTable]map[OpName]Handler
+	fallback           Handler
 }
 
-// New create an Hasura event trigger router
+// New create an Hasura event trigger router. The handlers map is copied so later registrations don't modify it
 func New(handlers map[string]Handler) *Router {
-	return &Router{
-		handlers:  handlers,
-		onSuccess: onSuccess,
-		onError:   onError,
+	handlersCopy := make(map[string]Handler, len(handlers))
+	for name, handler := range handlers {
+		handlersCopy[name] = handler
 	}
+	rt := &Router{
+		handlers:           handlersCopy,
+		logger:             logging.NewStdLogger(nil),
+		redactor:           tracing.NewRedactor(),
+		timeouts:           make(map[string]time.Duration),
+		handlerMiddlewares: make(map[string][]middleware.Middleware),
+		opHandlers:         make(map[string]map[OpName]Handler),
+		tableHandlers:      make(map[EventTable]map[OpName]Handler),
+	}
+	rt.onSuccess = rt.logSuccess
+	rt.onError = rt.logError
+
+	return rt
 }
 
 // WithDebug set debug mode to add input data to the tracing context
@@ -33,6 +69,85 @@ func (rt *Router) WithDebug(debug bool) *Router {
 	return rt
 }
 
+// WithTimeout set the default execution timeout of all handlers.
+// The timeout should be lower than the webhook timeout configured in Hasura
+func (rt *Router) WithTimeout(timeout time.Duration) *Router {
+	rt.timeout = timeout
+	return rt
+}
+
+// WithHandlerTimeout set the execution timeout of a handler that overrides the default timeout
+func (rt *Router) WithHandlerTimeout(name string, timeout time.Duration) *Router {
+	rt.timeouts[name] = timeout
+	return rt
+}
+
+// WithRepanic set whether recovered handler panics are re-panicked after being reported.
+// It only takes effect in debug mode and is useful for local development
+func (rt *Router) WithRepanic(repanic bool) *Router {
+	rt.repanic = repanic
+	return rt
+}
+
+// WithAuthenticator set the authenticator that verifies requests before the body is decoded
+func (rt *Router) WithAuthenticator(authenticator auth.Authenticator) *Router {
+	rt.authenticator = authenticator
+	return rt
+}
+
+// Use appends router-wide middlewares that wrap every handler invocation
+func (rt *Router) Use(middlewares ...middleware.Middleware) *Router {
+	rt.middlewares = append(rt.middlewares, middlewares...)
+	return rt
+}
+
+// UseHandler appends middlewares that only wrap the invocation of the named handler.
+// They are executed after router-wide middlewares
+func (rt *Router) UseHandler(name string, middlewares ...middleware.Middleware) *Router {
+	rt.handlerMiddlewares[name] = append(rt.handlerMiddlewares[name], middlewares...)
+	return rt
+}
+
+// WithIdempotency set the store that records completed event ids and their responses.
+// Redelivered events are responded with the cached response without executing the handler.
+// Concurrent deliveries of the same event are guarded by the store if it implements idempotency.Locker,
+// or by an in-process lock otherwise
+func (rt *Router) WithIdempotency(store idempotency.Store) *Router {
+	rt.idempotencyStore = store
+	if locker, ok := store.(idempotency.Locker); ok {
+		rt.idempotencyLocker = locker
+	} else {
+		rt.idempotencyLocker = idempotency.NewKeyMutex()
+	}
+	return rt
+}
+
+// WithLogger set the logger that the default success and error callbacks write to
+func (rt *Router) WithLogger(logger logging.Logger) *Router {
+	rt.logger = logger
+	return rt
+}
+
+// WithRedactor set the redactor that masks sensitive headers, session variables and debug fields in tracing.
+// The default redactor masks the admin secret, authorization and cookie headers. Set nil to disable the redaction
+func (rt *Router) WithRedactor(redactor *tracing.Redactor) *Router {
+	rt.redactor = redactor
+	return rt
+}
+
+// WithTracer set the tracer that starts a span per invocation.
+// The span is a child of the trace context propagated by W3C traceparent or B3 headers of the request
+func (rt *Router) WithTracer(tracer tracing.Tracer) *Router {
+	rt.tracer = tracer
+	return rt
+}
+
+// WithMetrics set the recorder that observes the latency and outcome of every invocation
+func (rt *Router) WithMetrics(recorder metrics.Recorder) *Router {
+	rt.metrics = recorder
+	return rt
+}
+
 // OnSuccess set a function to handle success callback
 func (rt *Router) OnSuccess(callback func(ctx *Context, response interface{}, metadata map[string]interface{})) {
 	rt.onSuccess = callback
@@ -48,26 +163,53 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	requestId := r.Header.Get(types.XRequestId)
 	tracer := tracing.New(requestId).WithFields(map[string]interface{}{
-		"type":         "event-trigger",
-		"http_headers": r.Header,
+		"type":         types.RouterTypeEventTrigger,
+		"http_headers": rt.redactor.RedactHeaders(r.Header),
 	})
 
+	spanContext, _ := tracing.StartRequestSpan(r.Context(), rt.tracer, types.RouterTypeEventTrigger, r.Header)
 	eventContext := &Context{
+		Context: tracing.NewContext(spanContext, tracer),
 		Headers: r.Header,
 		Tracing: tracer,
 	}
+	endDecode := tracer.StartPhase(tracing.PhaseDecode)
+	body, err := io.ReadAll(r.Body)
+	endDecode()
+	if err != nil {
+		rt.reportError(eventContext, err)
+		types.WriteWebhookError(w, types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("failed to read the request body: %s", err.Error())))
+		return
+	}
+
+	if rt.authenticator != nil {
+		endValidate := tracer.StartPhase(tracing.PhaseValidate)
+		err := rt.authenticator.Authenticate(r, body)
+		endValidate()
+		if err != nil {
+			rt.reportError(eventContext, err)
+			types.WriteWebhookError(w, err)
+			return
+		}
+	}
+
 	var payload EventTriggerPayload
-	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-		rt.onError(eventContext, err, tracer.Values())
-		sendError(w, types.ErrCodeBadRequest, fmt.Errorf("json body could not be decoded: %w", err))
+	endDecode = tracer.StartPhase(tracing.PhaseDecode)
+	err = json.Unmarshal(body, &payload)
+	endDecode()
+	if err != nil {
+		rt.reportError(eventContext, err)
+		types.WriteWebhookError(w, types.NewDecodeError(err))
 		return
 	}
 
 	tracer.SetRequestId(payload.ID)
+	eventContext.TriggerName = payload.Trigger.Name
+	eventContext.DeliveryInfo = payload.DeliveryInfo
 	tracer.WithFields(map[string]interface{}{
 		"event_name":        payload.Trigger.Name,
 		"op":                payload.Event.OP,
-		"session_variables": payload.Event.SessionVariables,
+		"session_variables": rt.redactor.RedactSessionVariables(payload.Event.SessionVariables),
 		"table_schema":      payload.Table.Schema,
 		"table_name":        payload.Table.Name,
 		"created_at":        payload.CreatedAt,
@@ -77,47 +219,110 @@ func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if rt.debug {
 		tracer = tracer.WithFields(map[string]interface{}{
-			"data_old": string(payload.Event.Data.Old),
-			"data_new": string(payload.Event.Data.New),
+			"data_old": rt.redactor.RedactJSON(payload.Event.Data.Old),
+			"data_new": rt.redactor.RedactJSON(payload.Event.Data.New),
 		})
 	}
 
-	if err := validateSessionVariables(payload.Event.SessionVariables); err != nil {
-		rt.onError(eventContext, err, tracer.Values())
-		sendError(w, types.ErrCodeBadRequest, err)
+	endValidate := tracer.StartPhase(tracing.PhaseValidate)
+	err = validateSessionVariables(payload.Event.SessionVariables)
+	endValidate()
+	if err != nil {
+		rt.reportError(eventContext, err)
+		types.WriteWebhookError(w, err)
 		return
 	}
 
+	eventContext.SessionVariables = types.NewSessionVariables(payload.Event.SessionVariables)
+	idempotencyKey := types.RouterTypeEventTrigger + ":" + payload.ID
+	if rt.idempotencyStore != nil && payload.ID != "" {
+		unlock, cached, err := idempotency.Acquire(eventContext, rt.idempotencyLocker, rt.idempotencyStore, idempotencyKey)
+		if err != nil {
+			rt.reportError(eventContext, err)
+			types.WriteWebhookError(w, err)
+			return
+		}
+		defer unlock()
+
+		if cached != nil {
+			tracer.WithField("idempotent_replay", true)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write(cached)
+			rt.reportSuccess(eventContext, json.RawMessage(cached))
+			return
+		}
+	}
+
 	jsonBytes, resp, err := rt.route(eventContext, payload)
 	w.Header().Set("Content-Type", "application/json")
 	if err != nil {
-		rt.onError(eventContext, err, tracer.Values())
-		sendError(w, types.ErrCodeBadRequest, err)
+		rt.sendDeadLetter(eventContext, payload, err)
+	}
+
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) {
+		rt.handlePanic(w, eventContext, panicErr)
+		return
+	}
+	if IsPermanentError(err) {
+		// acknowledge the event so Hasura doesn't retry it
+		tracer.WithField("permanent", true)
+		rt.reportError(eventContext, err)
+		responseBytes, _ := json.Marshal(types.ToError(err))
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBytes)
 		return
 	}
+	if err != nil {
+		rt.reportError(eventContext, err)
+		types.WriteWebhookError(w, err)
+		return
+	}
+
+	if rt.idempotencyStore != nil && payload.ID != "" {
+		if err := rt.idempotencyStore.Save(eventContext, idempotencyKey, jsonBytes); err != nil {
+			tracer.WithField("idempotency_error", err.Error())
+		}
+	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write(jsonBytes)
 
-	rt.onSuccess(eventContext, resp, tracer.Values())
+	rt.reportSuccess(eventContext, resp)
 }
 
 func (rt *Router) route(ctx *Context, payload EventTriggerPayload) ([]byte, interface{}, error) {
-	if rt.handlers == nil {
-		return nil, nil, fmt.Errorf("there should be at least one event handler")
-	}
-
-	handler, ok := rt.handlers[payload.Trigger.Name]
-	if !ok {
-		return nil, nil, fmt.Errorf("unknown event %s", payload.Trigger.Name)
+	handler, label := rt.resolve(payload)
+	ctx.handlerLabel = label
+	if handler == nil {
+		return nil, nil, types.NewError(types.ErrCodeNotFound, fmt.Sprintf("unknown event %s", payload.Trigger.Name))
 	}
 
-	resp, err := handler(ctx, payload)
+	invoke := middleware.Chain(middleware.Chain(func(mctx middleware.Context, mpayload interface{}) (interface{}, error) {
+		eventCtx, ok := mctx.(*Context)
+		if !ok {
+			eventCtx = ctx
+		}
+		eventPayload, ok := mpayload.(EventTriggerPayload)
+		if !ok {
+			eventPayload = payload
+		}
+		return handler(eventCtx, eventPayload)
+	}, rt.handlerMiddlewares[payload.Trigger.Name]...), rt.middlewares...)
+
+	endHandler := ctx.Tracing.StartPhase(tracing.PhaseHandler)
+	resp, err := rt.execute(ctx, payload.Trigger.Name, func(ctx *Context) (interface{}, error) {
+		return invoke(ctx, payload)
+	})
+	endHandler()
 	if err != nil {
 		return nil, nil, err
 	}
 
+	endEncode := ctx.Tracing.StartPhase(tracing.PhaseEncode)
 	bytes, jsonErr := json.Marshal(resp)
+	endEncode()
 	if jsonErr != nil {
 		return nil, nil, types.NewError(types.ErrCodeInternal, jsonErr.Error())
 	}
@@ -125,55 +330,89 @@ func (rt *Router) route(ctx *Context, payload EventTriggerPayload) ([]byte, inte
 	return bytes, resp, err
 }
 
-func sendError(w http.ResponseWriter, code string, err error) {
-	w.WriteHeader(400)
-
-	responseBytes, err := json.Marshal(map[string]interface{}{
-		"code":    code,
-		"message": err.Error(),
-		"error":   err,
+// execute runs the handler with the configured timeout and recovers panics
+func (rt *Router) execute(ctx *Context, name string, handler func(ctx *Context) (interface{}, error)) (interface{}, error) {
+	timeout, ok := rt.timeouts[name]
+	if !ok {
+		timeout = rt.timeout
+	}
+	return types.ExecuteWithTimeout(ctx.Context, "event trigger "+name, timeout, func(timeoutCtx context.Context) (interface{}, error) {
+		handlerCtx := *ctx
+		handlerCtx.Context = timeoutCtx
+		return handler(&handlerCtx)
 	})
+}
 
-	if err != nil {
-		w.Write([]byte(fmt.Sprintf(`{ "message": "ERROR: %s" }`, err)))
-		return
-	}
+// handlePanic reports the recovered panic of the handler to the error callback
+func (rt *Router) handlePanic(w http.ResponseWriter, ctx *Context, panicErr *types.PanicError) {
+	ctx.Tracing.WithFields(panicErr.Fields())
+	rt.reportError(ctx, panicErr)
+	types.WritePanicError(w, panicErr, types.WriteWebhookError, rt.debug && rt.repanic)
+}
 
-	w.Write(responseBytes)
+// reportSuccess ends the invocation span and calls the success callback
+func (rt *Router) reportSuccess(ctx *Context, response interface{}) {
+	metadata := ctx.Tracing.Values()
+	rt.endSpan(ctx, metadata, nil)
+	rt.observe(ctx, nil)
+	rt.onSuccess(ctx, response, metadata)
 }
 
-func onSuccess(ctx *Context, response interface{}, metadata map[string]interface{}) {
-	metadata["level"] = "info"
-	metadata["message"] = "executed action successfully"
+// reportError ends the invocation span with the error and calls the error callback
+func (rt *Router) reportError(ctx *Context, err error) {
+	metadata := ctx.Tracing.Values()
+	rt.endSpan(ctx, metadata, err)
+	rt.observe(ctx, err)
+	rt.onError(ctx, err, metadata)
+}
 
-	jsonBytes, err := json.Marshal(metadata)
-	if err != nil {
-		log.Println(metadata)
-		return
+func (rt *Router) endSpan(ctx *Context, metadata map[string]interface{}, err error) {
+	span := tracing.SpanFromContext(ctx)
+	if ctx.TriggerName != "" {
+		span.SetName(types.RouterTypeEventTrigger + " " + ctx.TriggerName)
 	}
-
-	log.Println(string(jsonBytes))
+	tracing.EndSpan(span, metadata, err)
 }
 
-func onError(ctx *Context, err error, metadata map[string]interface{}) {
-	metadata["level"] = "error"
-	metadata["error"] = err
-	metadata["message"] = err.Error()
-
-	jsonBytes, err := json.Marshal(metadata)
-	if err != nil {
-		log.Println(metadata)
+func (rt *Router) observe(ctx *Context, err error) {
+	if rt.metrics == nil {
 		return
 	}
+	rt.metrics.Observe(metrics.Observation{
+		RouterType: types.RouterTypeEventTrigger,
+		Handler:    handlerLabel(ctx),
+		Role:       ctx.SessionVariables.GetRole(),
+		ErrorCode:  metrics.ErrorCode(err),
+		Duration:   time.Since(ctx.Tracing.StartTime()),
+		Phases:     metrics.PhaseDurations(ctx.Tracing.Phases()),
+	})
+}
+
+// handlerLabel returns the label of the registration that handles the event.
+// Events that are rejected before routing or don't match any handler are labelled as unknown
+func handlerLabel(ctx *Context) string {
+	if ctx.handlerLabel == "" {
+		return metrics.UnknownHandler
+	}
+	return ctx.handlerLabel
+}
 
-	log.Println(string(jsonBytes))
+// logSuccess writes the success result to the logger. It is the default success callback
+func (rt *Router) logSuccess(ctx *Context, response interface{}, metadata map[string]interface{}) {
+	rt.logger.Log(ctx, logging.LevelInfo, "executed event trigger successfully", metadata)
+}
+
+// logError writes the error to the logger. It is the default error callback
+func (rt *Router) logError(ctx *Context, err error, metadata map[string]interface{}) {
+	metadata["error"] = err
+	rt.logger.Log(ctx, logging.LevelError, err.Error(), metadata)
 }
 
 func validateSessionVariables(variables map[string]string) error {
 	role, hasRole := variables[types.XHasuraRole]
 
 	if !hasRole || role == "" {
-		return fmt.Errorf("%s session variable is required", types.XHasuraRole)
+		return types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("%s session variable is required", types.XHasuraRole))
 	}
 	return nil
 }
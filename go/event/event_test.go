@@ -0,0 +1,411 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/idempotency"
+	"github.com/hgiasac/hasura-router/go/logging"
+	"github.com/hgiasac/hasura-router/go/metrics"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRequest(trigger string, op OpName, schema string, table string, data string) *http.Request {
+	body := []byte(`{
+		"id": "1",
+		"trigger": { "name": "` + trigger + `" },
+		"table": { "schema": "` + schema + `", "name": "` + table + `" },
+		"event": {
+			"op": "` + string(op) + `",
+			"session_variables": { "x-hasura-role": "admin" },
+			"data": ` + data + `
+		},
+		"delivery_info": { "max_retries": 0, "current_retry": 0 }
+	}`)
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func newNamedHandler(name string) Handler {
+	return func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+		return name, nil
+	}
+}
+
+func TestEventRouting(t *testing.T) {
+	router := New(map[string]Handler{
+		"userTrigger": newNamedHandler("trigger"),
+	}).
+		HandleOp("userTrigger", OpUpdate, newNamedHandler("trigger_update")).
+		HandleTable("public", "user", OpDelete, newNamedHandler("table_delete")).
+		HandleTable("public", "user", "", newNamedHandler("table")).
+		HandleFallback(newNamedHandler("fallback"))
+
+	fixtures := []struct {
+		Trigger  string
+		Op       OpName
+		Table    string
+		Expected string
+	}{
+		{"userTrigger", OpInsert, "user", `"trigger"`},
+		{"userTrigger", OpUpdate, "user", `"trigger_update"`},
+		{"", OpDelete, "user", `"table_delete"`},
+		{"", OpInsert, "user", `"table"`},
+		{"", OpInsert, "post", `"fallback"`},
+	}
+
+	for _, fixture := range fixtures {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newTestRequest(fixture.Trigger, fixture.Op, "public", fixture.Table, `{"old": null, "new": null}`))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, fixture.Expected, w.Body.String())
+	}
+}
+
+func TestEventTimeout(t *testing.T) {
+	router := New(map[string]Handler{
+		"slowTrigger": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+		"fastTrigger": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			_, hasDeadline := ctx.Deadline()
+			return hasDeadline, nil
+		},
+	}).WithTimeout(time.Second).WithHandlerTimeout("slowTrigger", 10*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("slowTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, types.ErrCodeTimeout, resp.Code)
+	assert.Equal(t, "event trigger slowTrigger exceeded the timeout of 10ms", resp.Message)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("fastTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Body.String())
+}
+
+func TestEventPanic(t *testing.T) {
+	var metadata map[string]interface{}
+	router := New(map[string]Handler{
+		"panicTrigger": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			panic("something went wrong")
+		},
+	})
+	router.OnError(func(ctx *Context, err error, m map[string]interface{}) {
+		metadata = m
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("panicTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, types.ErrCodeInternal, resp.Code)
+	assert.Equal(t, "internal server error", resp.Message)
+	assert.Equal(t, "something went wrong", metadata["panic"])
+	assert.Contains(t, metadata["stack"], "TestEventPanic")
+
+	// repanic only takes effect in debug mode
+	router.WithRepanic(true)
+	assert.NotPanics(t, func() {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest("panicTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	})
+	router.WithDebug(true)
+	assert.PanicsWithValue(t, "something went wrong", func() {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest("panicTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	})
+}
+
+func TestEventMetricsHandler(t *testing.T) {
+	var handlers []string
+	router := New(map[string]Handler{
+		"userTrigger": newNamedHandler("trigger"),
+	}).
+		HandleOp("postTrigger", OpInsert, newNamedHandler("post_insert")).
+		HandleTable("public", "user", "", newNamedHandler("table")).
+		WithMetrics(metrics.RecorderFunc(func(observation metrics.Observation) {
+			handlers = append(handlers, observation.Handler)
+		}))
+
+	for _, trigger := range []string{"userTrigger", "postTrigger", "anyTrigger", "unknownTrigger"} {
+		router.ServeHTTP(httptest.NewRecorder(), newTestRequest(trigger, OpInsert, "public", "user", `{"old": null, "new": null}`))
+	}
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("randomTrigger", OpInsert, "public", "post", `{"old": null, "new": null}`))
+	router.HandleFallback(newNamedHandler("fallback"))
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("randomTrigger", OpInsert, "public", "post", `{"old": null, "new": null}`))
+
+	assert.Equal(t, []string{
+		"userTrigger", "postTrigger", "public.user", "public.user", metrics.UnknownHandler, metrics.FallbackHandler,
+	}, handlers)
+}
+
+type testUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTypedHandler(t *testing.T) {
+	router := New(map[string]Handler{
+		"userTrigger": NewTypedHandler(func(ctx *Context, payload EventTriggerPayload, data TypedEventData[testUser]) ([]string, error) {
+			assert.Equal(t, "foo", data.Old.Name)
+			assert.Equal(t, "bar", data.New.Name)
+			return ChangedColumns(payload.Event.Data)
+		}),
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("userTrigger", OpUpdate, "public", "user", `{
+		"old": { "id": 1, "name": "foo", "tags": ["a"], "meta": { "a": 1, "b": 2 } },
+		"new": { "id": 1, "name": "bar", "tags": ["a", "b"], "meta": { "b": 2, "a": 1 } }
+	}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var columns []string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &columns))
+	assert.Equal(t, []string{"name", "tags"}, columns)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("userTrigger", OpUpdate, "public", "user", `{"old": null, "new": { "id": "1" }}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"path":"event.data.new.id"`)
+}
+
+func TestEventIdempotency(t *testing.T) {
+	calls := 0
+	router := New(map[string]Handler{
+		"userTrigger": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			calls++
+			return calls, nil
+		},
+	}).WithIdempotency(idempotency.NewMemoryStore(10))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, newTestRequest("userTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1", w.Body.String())
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestEventDeadLetter(t *testing.T) {
+	var letters []DeadLetter
+	router := New(map[string]Handler{
+		"failure": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			assert.True(t, ctx.IsLastAttempt())
+			return nil, types.NewError("failure", "failure")
+		},
+		"permanent": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			return nil, NewPermanentError(errors.New("bad event"))
+		},
+	}).WithDeadLetterSink(DeadLetterFunc(func(ctx context.Context, letter DeadLetter) error {
+		letters = append(letters, letter)
+		return nil
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("failure", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("permanent", OpInsert, "public", "user", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{"code":"unknown","message":"bad event","extensions":{"code":"unknown"}}`, w.Body.String())
+
+	assert.Len(t, letters, 2)
+	assert.Equal(t, "failure", letters[0].Error.Code)
+	assert.False(t, letters[0].Permanent)
+	assert.Equal(t, "permanent", letters[1].Payload.Trigger.Name)
+	assert.True(t, letters[1].Permanent)
+}
+
+func TestFanOut(t *testing.T) {
+	subscribers := []Subscriber{
+		{Name: "index", Handler: newNamedHandler("indexed")},
+		{Name: "cache", Handler: func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			return nil, types.NewError("cache_failure", "failed to invalidate cache")
+		}},
+		{Name: "notify", Handler: newNamedHandler("notified")},
+	}
+
+	fixtures := []struct {
+		Options    FanOutOptions
+		StatusCode int
+		Expected   string
+	}{
+		{
+			Options:    FanOutOptions{ErrorPolicy: FailFast},
+			StatusCode: http.StatusInternalServerError,
+			Expected:   `[{"name":"index","response":"indexed"},{"name":"cache","error":{"code":"cache_failure","message":"failed to invalidate cache","extensions":{"code":"cache_failure"}}},{"name":"notify","skipped":true}]`,
+		},
+		{
+			Options:    FanOutOptions{ErrorPolicy: CollectAll, Concurrent: true},
+			StatusCode: http.StatusInternalServerError,
+			Expected:   `[{"name":"index","response":"indexed"},{"name":"cache","error":{"code":"cache_failure","message":"failed to invalidate cache","extensions":{"code":"cache_failure"}}},{"name":"notify","response":"notified"}]`,
+		},
+		{
+			Options:    FanOutOptions{ErrorPolicy: BestEffort},
+			StatusCode: http.StatusOK,
+			Expected:   `[{"name":"index","response":"indexed"},{"name":"cache","error":{"code":"cache_failure","message":"failed to invalidate cache","extensions":{"code":"cache_failure"}}},{"name":"notify","response":"notified"}]`,
+		},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(string(fixture.Options.ErrorPolicy), func(t *testing.T) {
+			router := New(nil).Subscribe("userTrigger", fixture.Options, subscribers...)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newTestRequest("userTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+
+			var response struct {
+				Results    json.RawMessage `json:"results"`
+				Extensions struct {
+					Results json.RawMessage `json:"results"`
+				} `json:"extensions"`
+			}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			results := response.Results
+			if results == nil {
+				results = response.Extensions.Results
+			}
+			assert.Equal(t, fixture.Expected, string(results))
+		})
+	}
+}
+
+func TestSubscribeCopiesHandlers(t *testing.T) {
+	handlers := map[string]Handler{
+		"userTrigger": newNamedHandler("trigger"),
+	}
+	router := New(handlers).
+		Subscribe("postTrigger", FanOutOptions{}, Subscriber{Name: "index", Handler: newNamedHandler("indexed")})
+	assert.Len(t, handlers, 1)
+	assert.NotContains(t, handlers, "postTrigger")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, newTestRequest("postTrigger", OpInsert, "public", "post", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	New(nil).Subscribe("postTrigger", FanOutOptions{}).ServeHTTP(w, newTestRequest("postTrigger", OpInsert, "public", "post", `{"old": null, "new": null}`))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestFanOutPermanentError(t *testing.T) {
+	permanent := Subscriber{Name: "webhook", Handler: func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+		return nil, NewPermanentError(types.NewError("invalid_payload", "the payload is rejected"))
+	}}
+	retryable := Subscriber{Name: "cache", Handler: func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+		return nil, errors.New("failed to invalidate cache")
+	}}
+
+	fixtures := []struct {
+		Name        string
+		Subscribers []Subscriber
+		StatusCode  int
+		Permanent   bool
+	}{
+		{"permanent", []Subscriber{permanent}, http.StatusOK, true},
+		{"mixed", []Subscriber{permanent, retryable}, http.StatusInternalServerError, false},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			var letters []DeadLetter
+			router := New(nil).
+				Subscribe("userTrigger", FanOutOptions{ErrorPolicy: CollectAll}, fixture.Subscribers...).
+				WithDeadLetterSink(DeadLetterFunc(func(ctx context.Context, letter DeadLetter) error {
+					letters = append(letters, letter)
+					return nil
+				}))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, newTestRequest("userTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+
+			var resp types.Error
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, types.ErrCodeInternal, resp.Code)
+			assert.Len(t, letters, 1)
+			assert.Equal(t, fixture.Permanent, letters[0].Permanent)
+		})
+	}
+}
+
+func TestEventLogger(t *testing.T) {
+	var messages []string
+	router := New(map[string]Handler{
+		"user_created": newNamedHandler("user_created"),
+	}).WithLogger(logging.LoggerFunc(func(ctx context.Context, level logging.Level, message string, fields map[string]interface{}) {
+		messages = append(messages, level.String()+": "+message)
+	}))
+
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("user_created", OpInsert, "public", "users", `{"old": null, "new": {"id": 1}}`))
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("unknown", OpInsert, "public", "users", `{"old": null, "new": {"id": 1}}`))
+	assert.Equal(t, []string{
+		"info: executed event trigger successfully",
+		"error: not_found: unknown event unknown; extensions: map[code:not_found]",
+	}, messages)
+}
+
+func TestFanOutTracingScopes(t *testing.T) {
+	router := New(nil).Subscribe("userTrigger", FanOutOptions{Concurrent: true, ErrorPolicy: BestEffort},
+		Subscriber{Name: "index", Handler: func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			ctx.Tracing.WithField("documents", 1)
+			return "indexed", nil
+		}},
+		Subscriber{Name: "cache", Handler: func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			return nil, errors.New("failed to invalidate cache")
+		}},
+	)
+
+	var scopes []map[string]interface{}
+	router.OnSuccess(func(ctx *Context, response interface{}, metadata map[string]interface{}) {
+		scopes = metadata["scopes"].([]map[string]interface{})
+	})
+	router.ServeHTTP(httptest.NewRecorder(), newTestRequest("userTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`))
+
+	assert.Len(t, scopes, 2)
+	byName := make(map[interface{}]map[string]interface{})
+	for _, scope := range scopes {
+		byName[scope["scope"]] = scope
+	}
+	assert.Equal(t, 1, byName["index"]["documents"])
+	assert.Equal(t, "failed to invalidate cache", byName["cache"]["error"])
+}
+
+func TestEventAuthentication(t *testing.T) {
+	var called bool
+	router := New(map[string]Handler{
+		"userTrigger": func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+			called = true
+			return "ok", nil
+		},
+	}).WithAuthenticator(auth.NewSharedSecret("secret"))
+
+	// the malformed body would fail with 400 if it were decoded before authentication
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte("{"))))
+	var resp types.Error
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, types.ErrCodeUnauthorized, resp.Code)
+	assert.False(t, called)
+
+	r := newTestRequest("userTrigger", OpInsert, "public", "user", `{"old": null, "new": null}`)
+	r.Header.Set(auth.DefaultSecretHeader, "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, called)
+}
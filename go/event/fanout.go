@@ -0,0 +1,160 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// ErrorPolicy represents how errors of subscribers are handled
+type ErrorPolicy string
+
+const (
+	// FailFast stops at the first error. Concurrent subscribers are canceled via the context
+	FailFast ErrorPolicy = "fail_fast"
+	// CollectAll runs all subscribers and fails if any subscriber fails
+	CollectAll ErrorPolicy = "collect_all"
+	// BestEffort runs all subscribers and always succeeds. Errors are reported in the results
+	BestEffort ErrorPolicy = "best_effort"
+)
+
+// Subscriber represents a named handler of the fan-out execution
+type Subscriber struct {
+	Name    string
+	Handler Handler
+}
+
+// FanOutOptions represents the execution options of subscribers
+type FanOutOptions struct {
+	Concurrent  bool
+	ErrorPolicy ErrorPolicy
+}
+
+// SubscriberResult represents the execution result of a subscriber.
+// Permanent is true if the subscriber failed with a PermanentError
+type SubscriberResult struct {
+	Name      string       `json:"name"`
+	Response  interface{}  `json:"response,omitempty"`
+	Error     *types.Error `json:"error,omitempty"`
+	Permanent bool         `json:"permanent,omitempty"`
+	Skipped   bool         `json:"skipped,omitempty"`
+}
+
+// FanOutResponse represents the aggregated response of subscribers
+type FanOutResponse struct {
+	Results []SubscriberResult `json:"results"`
+}
+
+// NewFanOut creates a handler that executes many subscribers for one event.
+// The handler responds the result of each subscriber in the webhook body
+func NewFanOut(options FanOutOptions, subscribers ...Subscriber) Handler {
+	if options.ErrorPolicy == "" {
+		options.ErrorPolicy = FailFast
+	}
+
+	return func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+		var results []SubscriberResult
+		if options.Concurrent {
+			results = runConcurrent(ctx, payload, options.ErrorPolicy, subscribers)
+		} else {
+			results = runSequential(ctx, payload, options.ErrorPolicy, subscribers)
+		}
+
+		response := FanOutResponse{Results: results}
+		if options.ErrorPolicy == BestEffort {
+			return response, nil
+		}
+
+		var failures []string
+		permanent := true
+		for _, result := range results {
+			if result.Error != nil {
+				failures = append(failures, result.Name)
+				permanent = permanent && result.Permanent
+			}
+		}
+		if len(failures) == 0 {
+			return response, nil
+		}
+
+		err := types.NewError(types.ErrCodeInternal, fmt.Sprintf("subscribers failed: %v", failures))
+		err.Extensions["results"] = results
+		// the event isn't retried if all failed subscribers returned permanent errors
+		if permanent {
+			return nil, NewPermanentError(err)
+		}
+		return nil, err
+	}
+}
+
+// Subscribe registers subscribers of the trigger that are executed by a fan-out handler
+func (rt *Router) Subscribe(triggerName string, options FanOutOptions, subscribers ...Subscriber) *Router {
+	rt.handlers[triggerName] = NewFanOut(options, subscribers...)
+	return rt
+}
+
+// runSubscriber runs the subscriber with a child tracing scope that is nested in the event logs
+func runSubscriber(ctx *Context, payload EventTriggerPayload, subscriber Subscriber) SubscriberResult {
+	subscriberCtx := *ctx
+	if ctx.Tracing != nil {
+		subscriberCtx.Tracing = ctx.Tracing.Child(subscriber.Name)
+		subscriberCtx.Context = tracing.NewContext(ctx.Context, subscriberCtx.Tracing)
+		defer subscriberCtx.Tracing.End()
+	}
+
+	result := SubscriberResult{Name: subscriber.Name}
+	resp, err := types.CatchPanic(func() (interface{}, error) {
+		return subscriber.Handler(&subscriberCtx, payload)
+	})
+	if err != nil {
+		subscriberError := types.ToError(err)
+		result.Error = &subscriberError
+		result.Permanent = IsPermanentError(err)
+		if subscriberCtx.Tracing != nil {
+			subscriberCtx.Tracing.WithField("error", subscriberError.Message)
+		}
+		return result
+	}
+	result.Response = resp
+	return result
+}
+
+func runSequential(ctx *Context, payload EventTriggerPayload, policy ErrorPolicy, subscribers []Subscriber) []SubscriberResult {
+	results := make([]SubscriberResult, len(subscribers))
+	failed := false
+	for i, subscriber := range subscribers {
+		if failed {
+			results[i] = SubscriberResult{Name: subscriber.Name, Skipped: true}
+			continue
+		}
+		results[i] = runSubscriber(ctx, payload, subscriber)
+		failed = results[i].Error != nil && policy == FailFast
+	}
+	return results
+}
+
+func runConcurrent(ctx *Context, payload EventTriggerPayload, policy ErrorPolicy, subscribers []Subscriber) []SubscriberResult {
+	cancelCtx, cancel := context.WithCancel(ctx.Context)
+	defer cancel()
+
+	results := make([]SubscriberResult, len(subscribers))
+	var wg sync.WaitGroup
+	for i, subscriber := range subscribers {
+		wg.Add(1)
+		go func(i int, subscriber Subscriber) {
+			defer wg.Done()
+			subscriberCtx := *ctx
+			subscriberCtx.Context = cancelCtx
+			results[i] = runSubscriber(&subscriberCtx, payload, subscriber)
+			if results[i].Error != nil && policy == FailFast {
+				cancel()
+			}
+		}(i, subscriber)
+	}
+	wg.Wait()
+
+	return results
+}
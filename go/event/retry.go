@@ -0,0 +1,124 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// PermanentError represents a handler error that shouldn't be retried.
+// The router acknowledges the event with a successful status so Hasura stops the delivery
+type PermanentError struct {
+	Err error
+}
+
+// NewPermanentError wraps the error as a permanent error
+func NewPermanentError(err error) *PermanentError {
+	return &PermanentError{Err: err}
+}
+
+// Error implements the error interface
+func (pe *PermanentError) Error() string {
+	return pe.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (pe *PermanentError) Unwrap() error {
+	return pe.Err
+}
+
+// IsPermanentError checks if the error is or wraps a permanent error
+func IsPermanentError(err error) bool {
+	var permanentErr *PermanentError
+	return errors.As(err, &permanentErr)
+}
+
+// DeadLetter represents an event whose delivery is failed permanently
+type DeadLetter struct {
+	Payload   EventTriggerPayload `json:"payload"`
+	Error     types.Error         `json:"error"`
+	Permanent bool                `json:"permanent"`
+	FailedAt  time.Time           `json:"failed_at"`
+}
+
+// DeadLetterSink receives events that failed on the last retry or with a permanent error
+type DeadLetterSink interface {
+	Send(ctx context.Context, letter DeadLetter) error
+}
+
+// DeadLetterFunc is an adapter to allow the use of ordinary functions as dead-letter sinks
+type DeadLetterFunc func(ctx context.Context, letter DeadLetter) error
+
+// Send calls f(ctx, letter)
+func (f DeadLetterFunc) Send(ctx context.Context, letter DeadLetter) error {
+	return f(ctx, letter)
+}
+
+// FileDeadLetterSink appends dead letters to a file in JSON lines format
+type FileDeadLetterSink struct {
+	sync.Mutex
+	file *os.File
+}
+
+// NewFileDeadLetterSink opens the file in append mode. The file is created if it doesn't exist
+func NewFileDeadLetterSink(path string) (*FileDeadLetterSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileDeadLetterSink{file: file}, nil
+}
+
+// Send implements the DeadLetterSink interface
+func (fs *FileDeadLetterSink) Send(ctx context.Context, letter DeadLetter) error {
+	line, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+
+	fs.Lock()
+	defer fs.Unlock()
+	_, err = fs.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file
+func (fs *FileDeadLetterSink) Close() error {
+	fs.Lock()
+	defer fs.Unlock()
+	return fs.file.Close()
+}
+
+// WithDeadLetterSink set the sink that receives events failed on the last retry or with a permanent error
+func (rt *Router) WithDeadLetterSink(sink DeadLetterSink) *Router {
+	rt.deadLetterSink = sink
+	return rt
+}
+
+// sendDeadLetter sends the failed event to the dead-letter sink if it won't be retried
+func (rt *Router) sendDeadLetter(ctx *Context, payload EventTriggerPayload, err error) {
+	permanent := IsPermanentError(err)
+	if rt.deadLetterSink == nil || (!permanent && !ctx.IsLastAttempt()) {
+		return
+	}
+
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) {
+		err = types.NewError(types.ErrCodeInternal, panicErr.Error())
+	}
+
+	ctx.Tracing.WithField("dead_letter", true)
+	if sinkErr := rt.deadLetterSink.Send(ctx, DeadLetter{
+		Payload:   payload,
+		Error:     types.ToError(err),
+		Permanent: permanent,
+		FailedAt:  time.Now(),
+	}); sinkErr != nil {
+		ctx.Tracing.WithField("dead_letter_error", sinkErr.Error())
+	}
+}
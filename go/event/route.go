@@ -0,0 +1,58 @@
+package event
+
+import "github.com/hgiasac/hasura-router/go/metrics"
+
+// HandleOp registers the handler of an operation of the trigger.
+// It takes precedence over the handler of the trigger registered in New
+func (rt *Router) HandleOp(triggerName string, op OpName, handler Handler) *Router {
+	if _, ok := rt.opHandlers[triggerName]; !ok {
+		rt.opHandlers[triggerName] = make(map[OpName]Handler)
+	}
+	rt.opHandlers[triggerName][op] = handler
+	return rt
+}
+
+// HandleTable registers the handler of an operation on the table.
+// All operations are matched if the operation is empty.
+// Table handlers are used if there is no handler registered for the trigger name
+func (rt *Router) HandleTable(schema string, table string, op OpName, handler Handler) *Router {
+	key := EventTable{Schema: schema, Name: table}
+	if _, ok := rt.tableHandlers[key]; !ok {
+		rt.tableHandlers[key] = make(map[OpName]Handler)
+	}
+	rt.tableHandlers[key][op] = handler
+	return rt
+}
+
+// HandleFallback registers the handler of events that don't match any other handler
+func (rt *Router) HandleFallback(handler Handler) *Router {
+	rt.fallback = handler
+	return rt
+}
+
+// resolve finds the handler of the event in order of trigger and operation, trigger,
+// table and operation, table, then the fallback handler.
+// It also returns the metrics label of the matched registration, that is the trigger name,
+// the schema and table name joined by a dot, or the fallback label
+func (rt *Router) resolve(payload EventTriggerPayload) (Handler, string) {
+	if handler, ok := rt.opHandlers[payload.Trigger.Name][payload.Event.OP]; ok {
+		return handler, payload.Trigger.Name
+	}
+	if handler, ok := rt.handlers[payload.Trigger.Name]; ok {
+		return handler, payload.Trigger.Name
+	}
+	if handlers, ok := rt.tableHandlers[payload.Table]; ok {
+		label := payload.Table.Schema + "." + payload.Table.Name
+		if handler, ok := handlers[payload.Event.OP]; ok {
+			return handler, label
+		}
+		if handler, ok := handlers[""]; ok {
+			return handler, label
+		}
+	}
+	if rt.fallback != nil {
+		return rt.fallback, metrics.FallbackHandler
+	}
+
+	return nil, metrics.UnknownHandler
+}
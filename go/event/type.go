@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/hgiasac/hasura-router/go/middleware"
 	"github.com/hgiasac/hasura-router/go/tracing"
 	"github.com/hgiasac/hasura-router/go/types"
 )
@@ -30,6 +31,7 @@ type EventTriggerPayload struct {
 	DeliveryInfo DeliveryInfo `json:"delivery_info"`
 }
 
+// DeliveryInfo represents the retry information of the event delivery
 type DeliveryInfo struct {
 	MaxRetries   int `json:"max_retries"`
 	CurrentRetry int `json:"current_retry"`
@@ -61,6 +63,49 @@ type Handler func(ctx *Context, payload EventTriggerPayload) (interface{}, error
 // Context represents an extensible event context.
 type Context struct {
 	context.Context
-	Headers http.Header
-	Tracing *tracing.Tracing
+	Headers          http.Header
+	SessionVariables types.SessionVariables
+	Tracing          *tracing.Tracing
+	TriggerName      string
+	DeliveryInfo     DeliveryInfo
+
+	handlerLabel string
+}
+
+// IsLastAttempt checks if the current delivery is the last attempt that Hasura makes
+func (ctx *Context) IsLastAttempt() bool {
+	return ctx.DeliveryInfo.CurrentRetry >= ctx.DeliveryInfo.MaxRetries
+}
+
+var _ middleware.Context = (*Context)(nil)
+
+// RouterType returns the event trigger router type
+func (ctx *Context) RouterType() string {
+	return types.RouterTypeEventTrigger
+}
+
+// HandlerName returns the trigger name
+func (ctx *Context) HandlerName() string {
+	return ctx.TriggerName
+}
+
+// GetHeaders returns the request headers
+func (ctx *Context) GetHeaders() http.Header {
+	return ctx.Headers
+}
+
+// GetSessionVariables returns the session variables of the event
+func (ctx *Context) GetSessionVariables() types.SessionVariables {
+	return ctx.SessionVariables
+}
+
+// GetTracing returns the tracing instance
+func (ctx *Context) GetTracing() *tracing.Tracing {
+	return ctx.Tracing
+}
+
+// WithValue attaches a key-value pair to the embedded request context
+func (ctx *Context) WithValue(key interface{}, value interface{}) *Context {
+	ctx.Context = context.WithValue(ctx.Context, key, value)
+	return ctx
 }
@@ -0,0 +1,108 @@
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// TypedEventData represents the old and new row data decoded into a user type.
+// Old is nil for INSERT operations and New is nil for DELETE operations
+type TypedEventData[T any] struct {
+	Old *T
+	New *T
+}
+
+// TypedHandler represents an event handler with strongly typed row data and output.
+type TypedHandler[T any, Output any] func(ctx *Context, payload EventTriggerPayload, data TypedEventData[T]) (Output, error)
+
+// NewTypedHandler creates a generic Handler from a typed handler.
+// The old and new row data are decoded into the T type before the handler is executed
+func NewTypedHandler[T any, Output any](handler func(ctx *Context, payload EventTriggerPayload, data TypedEventData[T]) (Output, error)) Handler {
+	return TypedHandler[T, Output](handler).Handler()
+}
+
+// Handler converts the typed handler to the generic Handler that can be registered to the router
+func (th TypedHandler[T, Output]) Handler() Handler {
+	return func(ctx *Context, payload EventTriggerPayload) (interface{}, error) {
+		data, err := DecodeEventData[T](payload.Event.Data)
+		if err != nil {
+			return nil, err
+		}
+
+		return th(ctx, payload, data)
+	}
+}
+
+// DecodeEventData decodes the old and new row data into the T type
+func DecodeEventData[T any](data EventData) (TypedEventData[T], error) {
+	var result TypedEventData[T]
+	var err error
+	if result.Old, err = decodeRow[T](data.Old, "old"); err != nil {
+		return result, err
+	}
+	if result.New, err = decodeRow[T](data.New, "new"); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+func decodeRow[T any](raw json.RawMessage, name string) (*T, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var row T
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, types.NewDecodeError(err, "event", "data", name)
+	}
+
+	return &row, nil
+}
+
+// ChangedColumns returns sorted names of columns whose values are different between the old and new row data.
+// All columns of the new row are returned for INSERT operations and none for DELETE operations
+func ChangedColumns(data EventData) ([]string, error) {
+	var oldRow, newRow map[string]json.RawMessage
+	if len(data.Old) > 0 {
+		if err := json.Unmarshal(data.Old, &oldRow); err != nil {
+			return nil, types.NewDecodeError(err, "event", "data", "old")
+		}
+	}
+	if len(data.New) > 0 {
+		if err := json.Unmarshal(data.New, &newRow); err != nil {
+			return nil, types.NewDecodeError(err, "event", "data", "new")
+		}
+	}
+
+	var results []string
+	for column, newValue := range newRow {
+		oldValue, ok := oldRow[column]
+		if !ok || !jsonEqual(oldValue, newValue) {
+			results = append(results, column)
+		}
+	}
+	for column := range oldRow {
+		if _, ok := newRow[column]; !ok && newRow != nil {
+			results = append(results, column)
+		}
+	}
+	sort.Strings(results)
+
+	return results, nil
+}
+
+func jsonEqual(a json.RawMessage, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,61 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// FileStore is a Store that saves each completed key as a file in a directory.
+// It is suitable for single-instance deployments with a persistent volume
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a file store in the directory. The directory is created if it doesn't exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Get implements the Store interface
+func (fs *FileStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	response, err := os.ReadFile(fs.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return response, true, nil
+}
+
+// Save implements the Store interface. The file is written atomically
+func (fs *FileStore) Save(ctx context.Context, key string, response []byte) error {
+	file, err := os.CreateTemp(fs.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(response); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), fs.path(key))
+}
+
+// path returns the file path of the key. Keys are hashed to be safe file names
+func (fs *FileStore) path(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return filepath.Join(fs.dir, hex.EncodeToString(hash[:])+".json")
+}
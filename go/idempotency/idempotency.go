@@ -0,0 +1,93 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// Store records completed deliveries and their cached responses
+type Store interface {
+	// Get returns the cached response of the completed key, or false if the key isn't completed
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Save records the key as completed with the response
+	Save(ctx context.Context, key string, response []byte) error
+}
+
+// Locker guards concurrent deliveries of the same key.
+// Stores can implement this interface to lock keys across processes
+type Locker interface {
+	// Lock blocks until the key is acquired or the context is done. It returns the unlock function
+	Lock(ctx context.Context, key string) (func(), error)
+}
+
+// Acquire locks the key and returns the unlock function with the cached response if the key is completed.
+// The response is nil if the key isn't completed, and the key stays locked until unlock is called
+func Acquire(ctx context.Context, locker Locker, store Store, key string) (func(), []byte, error) {
+	unlock, err := locker.Lock(ctx, key)
+	if err != nil {
+		return nil, nil, types.NewError(types.ErrCodeUnavailable, fmt.Sprintf("failed to acquire the idempotency lock: %s", err))
+	}
+
+	cached, ok, err := store.Get(ctx, key)
+	if err != nil {
+		unlock()
+		return nil, nil, types.NewError(types.ErrCodeInternal, fmt.Sprintf("failed to get the idempotency record: %s", err))
+	}
+	if !ok {
+		return unlock, nil, nil
+	}
+	return unlock, cached, nil
+}
+
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// KeyMutex is an in-process Locker that locks keys independently
+type KeyMutex struct {
+	sync.Mutex
+	locks map[string]*keyLock
+}
+
+// NewKeyMutex creates an in-process key locker
+func NewKeyMutex() *KeyMutex {
+	return &KeyMutex{
+		locks: make(map[string]*keyLock),
+	}
+}
+
+// Lock implements the Locker interface
+func (km *KeyMutex) Lock(ctx context.Context, key string) (func(), error) {
+	km.Mutex.Lock()
+	lock, ok := km.locks[key]
+	if !ok {
+		lock = &keyLock{ch: make(chan struct{}, 1)}
+		km.locks[key] = lock
+	}
+	lock.refs++
+	km.Mutex.Unlock()
+
+	select {
+	case lock.ch <- struct{}{}:
+		return func() {
+			<-lock.ch
+			km.release(key, lock)
+		}, nil
+	case <-ctx.Done():
+		km.release(key, lock)
+		return nil, ctx.Err()
+	}
+}
+
+func (km *KeyMutex) release(key string, lock *keyLock) {
+	km.Mutex.Lock()
+	defer km.Mutex.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(km.locks, key)
+	}
+}
@@ -0,0 +1,77 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore(2)
+
+	assert.NoError(t, store.Save(ctx, "a", []byte("1")))
+	assert.NoError(t, store.Save(ctx, "b", []byte("2")))
+	_, ok, _ := store.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.NoError(t, store.Save(ctx, "c", []byte("3")))
+
+	_, ok, _ = store.Get(ctx, "b")
+	assert.False(t, ok)
+	response, ok, _ := store.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), response)
+	assert.Equal(t, 2, store.Len())
+
+	store.WithTTL(time.Nanosecond)
+	assert.NoError(t, store.Save(ctx, "d", []byte("4")))
+	time.Sleep(time.Millisecond)
+	_, ok, _ = store.Get(ctx, "d")
+	assert.False(t, ok)
+}
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	assert.NoError(t, err)
+
+	_, ok, err := store.Get(ctx, "a/b")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Save(ctx, "a/b", []byte(`{"foo":"bar"}`)))
+	response, ok, err := store.Get(ctx, "a/b")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`{"foo":"bar"}`), response)
+}
+
+func TestKeyMutex(t *testing.T) {
+	locker := NewKeyMutex()
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := locker.Lock(context.Background(), "a")
+			assert.NoError(t, err)
+			defer unlock()
+			counter++
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, counter)
+	assert.Len(t, locker.locks, 0)
+
+	unlock, err := locker.Lock(context.Background(), "a")
+	assert.NoError(t, err)
+	defer unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, err = locker.Lock(ctx, "a")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
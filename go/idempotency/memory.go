@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const DefaultMemoryCapacity = 10000
+
+type memoryEntry struct {
+	key       string
+	response  []byte
+	expiresAt time.Time
+}
+
+// MemoryStore is an in-memory Store that evicts the least recently used keys
+type MemoryStore struct {
+	sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+// NewMemoryStore creates an in-memory LRU store with the maximum number of keys
+func NewMemoryStore(capacity int) *MemoryStore {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+	return &MemoryStore{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// WithTTL set the duration that keys are kept. Keys are kept until evicted if the ttl is zero
+func (ms *MemoryStore) WithTTL(ttl time.Duration) *MemoryStore {
+	ms.ttl = ttl
+	return ms
+}
+
+// Get implements the Store interface
+func (ms *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	element, ok := ms.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		ms.order.Remove(element)
+		delete(ms.entries, key)
+		return nil, false, nil
+	}
+	ms.order.MoveToFront(element)
+
+	return entry.response, true, nil
+}
+
+// Save implements the Store interface
+func (ms *MemoryStore) Save(ctx context.Context, key string, response []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+
+	entry := &memoryEntry{
+		key:      key,
+		response: response,
+	}
+	if ms.ttl > 0 {
+		entry.expiresAt = time.Now().Add(ms.ttl)
+	}
+
+	if element, ok := ms.entries[key]; ok {
+		element.Value = entry
+		ms.order.MoveToFront(element)
+		return nil
+	}
+
+	ms.entries[key] = ms.order.PushFront(entry)
+	for ms.order.Len() > ms.capacity {
+		oldest := ms.order.Back()
+		ms.order.Remove(oldest)
+		delete(ms.entries, oldest.Value.(*memoryEntry).key)
+	}
+
+	return nil
+}
+
+// Len returns the number of stored keys
+func (ms *MemoryStore) Len() int {
+	ms.Lock()
+	defer ms.Unlock()
+	return ms.order.Len()
+}
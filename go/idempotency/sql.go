@@ -0,0 +1,84 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const DefaultSQLTable = "hasura_router_idempotency"
+
+// SQLStore is a Store that saves completed keys in a SQL table.
+// The database driver must be imported by the application.
+// The default queries support PostgreSQL and SQLite, other databases need WithPlaceholder and WithInsertQuery.
+// SQLStore doesn't implement Locker, so concurrent deliveries of the same key on different processes
+// may both execute the handler. The first saved response of the key is kept
+type SQLStore struct {
+	db          *sql.DB
+	table       string
+	placeholder func(index int) string
+	insertQuery string
+}
+
+// NewSQLStore creates a SQL store in the table. PostgreSQL placeholders ($1, $2) are used by default
+func NewSQLStore(db *sql.DB, table string) *SQLStore {
+	if table == "" {
+		table = DefaultSQLTable
+	}
+	return &SQLStore{
+		db:    db,
+		table: table,
+		placeholder: func(index int) string {
+			return fmt.Sprintf("$%d", index)
+		},
+	}
+}
+
+// WithPlaceholder set the function that formats query placeholders of the database driver, e.g. ? for SQLite
+func (ss *SQLStore) WithPlaceholder(placeholder func(index int) string) *SQLStore {
+	ss.placeholder = placeholder
+	return ss
+}
+
+// WithInsertQuery set the statement that inserts the key and the response as the first and second arguments,
+// and ignores the conflict if the key exists, e.g. INSERT IGNORE INTO hasura_router_idempotency (id, response) VALUES (?, ?) for MySQL.
+// The default statement uses ON CONFLICT (id) DO NOTHING
+func (ss *SQLStore) WithInsertQuery(query string) *SQLStore {
+	ss.insertQuery = query
+	return ss
+}
+
+// CreateTable creates the table if it doesn't exist
+func (ss *SQLStore) CreateTable(ctx context.Context) error {
+	_, err := ss.db.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id VARCHAR(255) PRIMARY KEY,
+	response TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`, ss.table))
+	return err
+}
+
+// Get implements the Store interface
+func (ss *SQLStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var response string
+	err := ss.db.QueryRowContext(ctx, fmt.Sprintf("SELECT response FROM %s WHERE id = %s", ss.table, ss.placeholder(1)), key).
+		Scan(&response)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return []byte(response), true, nil
+}
+
+// Save implements the Store interface. The first saved response of the key is kept
+func (ss *SQLStore) Save(ctx context.Context, key string, response []byte) error {
+	query := ss.insertQuery
+	if query == "" {
+		query = fmt.Sprintf("INSERT INTO %s (id, response) VALUES (%s, %s) ON CONFLICT (id) DO NOTHING", ss.table, ss.placeholder(1), ss.placeholder(2))
+	}
+	_, err := ss.db.ExecContext(ctx, query, key, string(response))
+	return err
+}
@@ -0,0 +1,159 @@
+package idempotency
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordDriver is a database/sql driver that records statements and keeps inserted rows in memory.
+// INSERT statements keep the first response of the key, and SELECT statements return it
+type recordDriver struct {
+	sync.Mutex
+	queries []string
+	rows    map[string]string
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) {
+	return &recordConn{driver: d}, nil
+}
+
+type recordConn struct {
+	driver *recordDriver
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return &recordStmt{driver: c.driver, query: query}, nil
+}
+
+func (c *recordConn) Close() error {
+	return nil
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return nil, driver.ErrSkip
+}
+
+type recordStmt struct {
+	driver *recordDriver
+	query  string
+}
+
+func (s *recordStmt) Close() error {
+	return nil
+}
+
+func (s *recordStmt) NumInput() int {
+	return -1
+}
+
+func (s *recordStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.driver.Lock()
+	defer s.driver.Unlock()
+	s.driver.queries = append(s.driver.queries, s.query)
+	if strings.HasPrefix(s.query, "INSERT") {
+		key := args[0].(string)
+		if _, ok := s.driver.rows[key]; !ok {
+			s.driver.rows[key] = args[1].(string)
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *recordStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.driver.Lock()
+	defer s.driver.Unlock()
+	s.driver.queries = append(s.driver.queries, s.query)
+	rows := &recordRows{}
+	if response, ok := s.driver.rows[args[0].(string)]; ok {
+		rows.values = []string{response}
+	}
+	return rows, nil
+}
+
+type recordRows struct {
+	values []string
+}
+
+func (r *recordRows) Columns() []string {
+	return []string{"response"}
+}
+
+func (r *recordRows) Close() error {
+	return nil
+}
+
+func (r *recordRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.values[0]
+	r.values = r.values[1:]
+	return nil
+}
+
+func openRecordDB(t *testing.T) (*sql.DB, *recordDriver) {
+	d := &recordDriver{rows: make(map[string]string)}
+	name := "record_" + t.Name()
+	sql.Register(name, d)
+	db, err := sql.Open(name, "")
+	assert.NoError(t, err)
+	return db, d
+}
+
+func TestSQLStore(t *testing.T) {
+	ctx := context.Background()
+	db, d := openRecordDB(t)
+	defer db.Close()
+
+	store := NewSQLStore(db, "")
+	assert.NoError(t, store.CreateTable(ctx))
+
+	_, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, store.Save(ctx, "a", []byte(`"ok"`)))
+	assert.NoError(t, store.Save(ctx, "a", []byte(`"other"`)))
+	response, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte(`"ok"`), response)
+
+	assert.Len(t, d.queries, 5)
+	assert.True(t, strings.HasPrefix(d.queries[0], "CREATE TABLE IF NOT EXISTS hasura_router_idempotency"))
+	assert.Equal(t, []string{
+		"SELECT response FROM hasura_router_idempotency WHERE id = $1",
+		"INSERT INTO hasura_router_idempotency (id, response) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING",
+		"INSERT INTO hasura_router_idempotency (id, response) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING",
+		"SELECT response FROM hasura_router_idempotency WHERE id = $1",
+	}, d.queries[1:])
+}
+
+func TestSQLStoreCustomQueries(t *testing.T) {
+	ctx := context.Background()
+	db, d := openRecordDB(t)
+	defer db.Close()
+
+	store := NewSQLStore(db, "events").
+		WithPlaceholder(func(index int) string {
+			return "?"
+		}).
+		WithInsertQuery("INSERT IGNORE INTO events (id, response) VALUES (?, ?)")
+
+	_, ok, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.NoError(t, store.Save(ctx, "a", []byte("1")))
+
+	assert.Equal(t, []string{
+		"SELECT response FROM events WHERE id = ?",
+		"INSERT IGNORE INTO events (id, response) VALUES (?, ?)",
+	}, d.queries)
+}
@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+)
+
+// Level represents the logging level
+type Level int
+
+const (
+	LevelDebug Level = iota - 1
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+// String returns the name of the level
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	case LevelError:
+		return "error"
+	default:
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+}
+
+// Logger represents a structured logger that routers write invocation results to
+type Logger interface {
+	Log(ctx context.Context, level Level, message string, fields map[string]interface{})
+}
+
+// LoggerFunc is an adapter to allow the use of ordinary functions as Logger
+type LoggerFunc func(ctx context.Context, level Level, message string, fields map[string]interface{})
+
+// Log implements the Logger interface
+func (fn LoggerFunc) Log(ctx context.Context, level Level, message string, fields map[string]interface{}) {
+	fn(ctx, level, message, fields)
+}
+
+// StdLogger writes logs as JSON lines to the standard library logger
+type StdLogger struct {
+	logger   *log.Logger
+	minLevel Level
+}
+
+// NewStdLogger creates a JSON logger with the standard library logger. Use the default logger if the input is nil
+func NewStdLogger(logger *log.Logger) *StdLogger {
+	return &StdLogger{
+		logger:   logger,
+		minLevel: LevelInfo,
+	}
+}
+
+// WithLevel set the minimum level of logs to be written
+func (sl *StdLogger) WithLevel(level Level) *StdLogger {
+	sl.minLevel = level
+	return sl
+}
+
+// Log implements the Logger interface
+func (sl *StdLogger) Log(ctx context.Context, level Level, message string, fields map[string]interface{}) {
+	if level < sl.minLevel {
+		return
+	}
+
+	entry := make(map[string]interface{}, len(fields)+2)
+	for key, value := range fields {
+		// errors without exported fields are encoded as empty objects
+		if err, ok := value.(error); ok {
+			if errBytes, jsonErr := json.Marshal(err); jsonErr != nil || string(errBytes) == "{}" {
+				value = err.Error()
+			}
+		}
+		entry[key] = value
+	}
+	entry["level"] = level.String()
+	entry["message"] = message
+
+	jsonBytes, err := json.Marshal(entry)
+	if err != nil {
+		sl.println(entry)
+		return
+	}
+	sl.println(string(jsonBytes))
+}
+
+func (sl *StdLogger) println(value interface{}) {
+	if sl.logger == nil {
+		log.Println(value)
+		return
+	}
+	sl.logger.Println(value)
+}
+
+// RedactedLogger is a Logger wrapper that masks values of sensitive fields with the redactor before they are written
+type RedactedLogger struct {
+	logger   Logger
+	redactor *tracing.Redactor
+}
+
+// NewRedactedLogger wraps the logger to redact fields by field rules of the redactor, see tracing.Redactor.WithFields
+func NewRedactedLogger(logger Logger, redactor *tracing.Redactor) *RedactedLogger {
+	return &RedactedLogger{
+		logger:   logger,
+		redactor: redactor,
+	}
+}
+
+// Log implements the Logger interface
+func (rl *RedactedLogger) Log(ctx context.Context, level Level, message string, fields map[string]interface{}) {
+	rl.logger.Log(ctx, level, message, rl.redactor.RedactFields(fields))
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0)).WithLevel(LevelInfo)
+
+	logger.Log(context.Background(), LevelDebug, "ignored", nil)
+	assert.Equal(t, "", buf.String())
+
+	logger.Log(context.Background(), LevelError, "failed", map[string]interface{}{
+		"error": errors.New("failed"),
+		"type":  "action",
+	})
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, map[string]interface{}{
+		"level":   "error",
+		"message": "failed",
+		"error":   "failed",
+		"type":    "action",
+	}, entry)
+}
+
+func TestRedactedLogger(t *testing.T) {
+	var results map[string]interface{}
+	logger := NewRedactedLogger(LoggerFunc(func(ctx context.Context, level Level, message string, fields map[string]interface{}) {
+		results = fields
+	}), tracing.NewEmptyRedactor().
+		WithFields(tracing.MaskRedact, "Authorization", "password", "x-hasura-admin-secret"))
+
+	headers := http.Header{}
+	headers.Set("Authorization", "Bearer token")
+	headers.Set("Content-Type", "application/json")
+	fields := map[string]interface{}{
+		"http_headers": headers,
+		"session_variables": map[string]string{
+			"x-hasura-role":         "admin",
+			"x-hasura-admin-secret": "secret",
+		},
+		"input": map[string]interface{}{
+			"user": map[string]interface{}{
+				"email":    "foo@example.com",
+				"password": "secret",
+			},
+		},
+		"password": "secret",
+	}
+	logger.Log(context.Background(), LevelInfo, "ok", fields)
+
+	assert.Equal(t, map[string][]string{
+		"Authorization": {tracing.RedactedValue},
+		"Content-Type":  {"application/json"},
+	}, results["http_headers"])
+	assert.Equal(t, map[string]string{
+		"x-hasura-role":         "admin",
+		"x-hasura-admin-secret": tracing.RedactedValue,
+	}, results["session_variables"])
+	assert.Equal(t, map[string]interface{}{
+		"user": map[string]interface{}{
+			"email":    "foo@example.com",
+			"password": tracing.RedactedValue,
+		},
+	}, results["input"])
+	assert.Equal(t, tracing.RedactedValue, results["password"])
+	// the input fields are not mutated
+	assert.Equal(t, "Bearer token", headers.Get("Authorization"))
+	assert.Equal(t, "secret", fields["password"])
+}
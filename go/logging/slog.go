@@ -0,0 +1,52 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+)
+
+// SlogLogger is a Logger adapter of the standard library log/slog logger
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger creates a Logger adapter of the slog logger. Use the default logger if the input is nil
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	return &SlogLogger{logger: logger}
+}
+
+// Log implements the Logger interface
+func (sl *SlogLogger) Log(ctx context.Context, level Level, message string, fields map[string]interface{}) {
+	logger := sl.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+	for _, key := range keys {
+		attrs = append(attrs, slog.Any(key, fields[key]))
+	}
+	logger.LogAttrs(ctx, toSlogLevel(level), message, attrs...)
+}
+
+func toSlogLevel(level Level) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -0,0 +1,27 @@
+//go:build go1.21
+
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	logger.Log(context.Background(), LevelWarn, "executed action successfully", map[string]interface{}{
+		"action": "hello",
+	})
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "executed action successfully", entry["msg"])
+	assert.Equal(t, "hello", entry["action"])
+}
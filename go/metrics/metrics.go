@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusError   = "error"
+)
+
+const (
+	// UnknownHandler is the handler label of invocations that don't match any registered handler,
+	// so arbitrary names in request payloads don't create new label values
+	UnknownHandler = "unknown"
+	// FallbackHandler is the handler label of events that are routed to the fallback handler
+	FallbackHandler = "fallback"
+	// OtherRole is the role label of roles that aren't allowed by the recorder
+	OtherRole = "other"
+)
+
+// Observation represents the outcome of a handler invocation
+type Observation struct {
+	RouterType string
+	Handler    string
+	Role       string
+	// ErrorCode is empty if the invocation succeeded
+	ErrorCode string
+	Duration  time.Duration
+	// Phases are durations of named phases of the invocation, e.g. decode, handler and encode
+	Phases map[string]time.Duration
+}
+
+// Status returns the success or error status of the invocation
+func (o Observation) Status() string {
+	if o.ErrorCode == "" {
+		return StatusSuccess
+	}
+	return StatusError
+}
+
+// Recorder represents a pluggable metrics backend that routers report invocations to
+type Recorder interface {
+	Observe(observation Observation)
+}
+
+// RecorderFunc is an adapter to allow the use of ordinary functions as Recorder
+type RecorderFunc func(observation Observation)
+
+// Observe implements the Recorder interface
+func (fn RecorderFunc) Observe(observation Observation) {
+	fn(observation)
+}
+
+// ErrorCode returns the error code label of the error. Recovered panics are internal errors
+func ErrorCode(err error) string {
+	if err == nil {
+		return ""
+	}
+	var panicErr *types.PanicError
+	if errors.As(err, &panicErr) {
+		return types.ErrCodeInternal
+	}
+	return types.ToError(err).Code
+}
+
+// PhaseDurations converts tracing phases to the phase durations of the observation
+func PhaseDurations(phases []tracing.Phase) map[string]time.Duration {
+	if len(phases) == 0 {
+		return nil
+	}
+	results := make(map[string]time.Duration, len(phases))
+	for _, phase := range phases {
+		results[phase.Name] += phase.Duration
+	}
+	return results
+}
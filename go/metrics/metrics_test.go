@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "", ErrorCode(nil))
+	assert.Equal(t, types.ErrCodeNotFound, ErrorCode(types.NewError(types.ErrCodeNotFound, "not found")))
+	assert.Equal(t, types.ErrCodeUnknown, ErrorCode(errors.New("failed")))
+	assert.Equal(t, types.ErrCodeInternal, ErrorCode(&types.PanicError{Value: "boom"}))
+}
+
+func TestPrometheusRecorder(t *testing.T) {
+	recorder := NewPrometheusRecorder().WithBuckets(1, 0.1).WithRoles("user", "admin")
+	recorder.Observe(Observation{RouterType: "action", Handler: "hello", Role: "user", Duration: 50 * time.Millisecond})
+	recorder.Observe(Observation{RouterType: "action", Handler: "hello", Role: "user", Duration: 500 * time.Millisecond})
+	recorder.Observe(Observation{RouterType: "event-trigger", Handler: `user"insert`, Role: "admin", ErrorCode: "bad_request", Duration: 2 * time.Second})
+
+	w := httptest.NewRecorder()
+	recorder.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, strings.Join([]string{
+		`# HELP hasura_router_invocations_total Total number of handler invocations.`,
+		`# TYPE hasura_router_invocations_total counter`,
+		`hasura_router_invocations_total{router="action",handler="hello",role="user",status="success",error_code=""} 2`,
+		`hasura_router_invocations_total{router="event-trigger",handler="user\"insert",role="admin",status="error",error_code="bad_request"} 1`,
+		`# HELP hasura_router_invocation_duration_seconds Latency of handler invocations in seconds.`,
+		`# TYPE hasura_router_invocation_duration_seconds histogram`,
+		`hasura_router_invocation_duration_seconds_bucket{router="action",handler="hello",role="user",status="success",le="0.1"} 1`,
+		`hasura_router_invocation_duration_seconds_bucket{router="action",handler="hello",role="user",status="success",le="1"} 2`,
+		`hasura_router_invocation_duration_seconds_bucket{router="action",handler="hello",role="user",status="success",le="+Inf"} 2`,
+		`hasura_router_invocation_duration_seconds_sum{router="action",handler="hello",role="user",status="success"} 0.55`,
+		`hasura_router_invocation_duration_seconds_count{router="action",handler="hello",role="user",status="success"} 2`,
+		`hasura_router_invocation_duration_seconds_bucket{router="event-trigger",handler="user\"insert",role="admin",status="error",le="0.1"} 0`,
+		`hasura_router_invocation_duration_seconds_bucket{router="event-trigger",handler="user\"insert",role="admin",status="error",le="1"} 0`,
+		`hasura_router_invocation_duration_seconds_bucket{router="event-trigger",handler="user\"insert",role="admin",status="error",le="+Inf"} 1`,
+		`hasura_router_invocation_duration_seconds_sum{router="event-trigger",handler="user\"insert",role="admin",status="error"} 2`,
+		`hasura_router_invocation_duration_seconds_count{router="event-trigger",handler="user\"insert",role="admin",status="error"} 1`,
+		``,
+	}, "\n"), w.Body.String())
+}
+
+func TestPrometheusPhases(t *testing.T) {
+	recorder := NewPrometheusRecorder().WithBuckets(0.1)
+	recorder.Observe(Observation{
+		RouterType: "action",
+		Handler:    "hello",
+		Duration:   50 * time.Millisecond,
+		Phases:     PhaseDurations([]tracing.Phase{{Name: tracing.PhaseHandler, Duration: 40 * time.Millisecond}}),
+	})
+
+	var sb strings.Builder
+	assert.NoError(t, recorder.Write(&sb))
+	assert.Contains(t, sb.String(), strings.Join([]string{
+		`# TYPE hasura_router_phase_duration_seconds histogram`,
+		`hasura_router_phase_duration_seconds_bucket{router="action",handler="hello",phase="handler",le="0.1"} 1`,
+		`hasura_router_phase_duration_seconds_bucket{router="action",handler="hello",phase="handler",le="+Inf"} 1`,
+		`hasura_router_phase_duration_seconds_sum{router="action",handler="hello",phase="handler"} 0.04`,
+		`hasura_router_phase_duration_seconds_count{router="action",handler="hello",phase="handler"} 1`,
+	}, "\n"))
+}
+
+func TestPrometheusRoles(t *testing.T) {
+	recorder := NewPrometheusRecorder().WithRoles("admin")
+	for _, role := range []string{"admin", "tenant_1", "tenant_2", ""} {
+		recorder.Observe(Observation{RouterType: "action", Handler: "hello", Role: role})
+	}
+
+	var sb strings.Builder
+	assert.NoError(t, recorder.Write(&sb))
+	assert.Contains(t, sb.String(), `hasura_router_invocations_total{router="action",handler="hello",role="",status="success",error_code=""} 1`)
+	assert.Contains(t, sb.String(), `hasura_router_invocations_total{router="action",handler="hello",role="admin",status="success",error_code=""} 1`)
+	assert.Contains(t, sb.String(), `hasura_router_invocations_total{router="action",handler="hello",role="other",status="success",error_code=""} 2`)
+	assert.NotContains(t, sb.String(), "tenant")
+}
+
+func TestPrometheusBucketsAfterObservation(t *testing.T) {
+	recorder := NewPrometheusRecorder()
+	recorder.Observe(Observation{
+		RouterType: "action",
+		Handler:    "hello",
+		Duration:   50 * time.Millisecond,
+		Phases:     map[string]time.Duration{tracing.PhaseHandler: 40 * time.Millisecond},
+	})
+
+	recorder.WithBuckets(0.1)
+	recorder.Observe(Observation{RouterType: "action", Handler: "hello", Duration: 50 * time.Millisecond})
+
+	var sb strings.Builder
+	assert.NoError(t, recorder.Write(&sb))
+	assert.Contains(t, sb.String(), `hasura_router_invocations_total{router="action",handler="hello",role="",status="success",error_code=""} 2`)
+	assert.Contains(t, sb.String(), `hasura_router_invocation_duration_seconds_bucket{router="action",handler="hello",role="",status="success",le="0.1"} 1`)
+	assert.Contains(t, sb.String(), `hasura_router_invocation_duration_seconds_count{router="action",handler="hello",role="",status="success"} 1`)
+	assert.NotContains(t, sb.String(), "phase_duration_seconds")
+}
@@ -0,0 +1,269 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default latency histogram buckets in seconds
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+type counterKey struct {
+	router    string
+	handler   string
+	role      string
+	status    string
+	errorCode string
+}
+
+type histogramKey struct {
+	router  string
+	handler string
+	role    string
+	status  string
+}
+
+type phaseKey struct {
+	router  string
+	handler string
+	phase   string
+}
+
+type histogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+}
+
+// PrometheusRecorder is an in-memory Recorder that exposes invocation counters and latency histograms
+// in the Prometheus text exposition format.
+//
+// Only roles that are set by WithRoles are recorded in the role label. Every other role,
+// including all roles when WithRoles isn't called, is recorded as OtherRole.
+// The allowed roles of actions can be wired from the permission table, e.g. WithRoles(actionRouter.Roles()...)
+type PrometheusRecorder struct {
+	sync.Mutex
+	namespace  string
+	buckets    []float64
+	roles      map[string]bool
+	counters   map[counterKey]uint64
+	histograms map[histogramKey]*histogram
+	phases     map[phaseKey]*histogram
+}
+
+// NewPrometheusRecorder creates a Prometheus recorder with the default namespace and buckets.
+// Call WithRoles to record roles other than OtherRole
+func NewPrometheusRecorder() *PrometheusRecorder {
+	return &PrometheusRecorder{
+		namespace:  "hasura_router",
+		buckets:    DefaultBuckets,
+		counters:   make(map[counterKey]uint64),
+		histograms: make(map[histogramKey]*histogram),
+		phases:     make(map[phaseKey]*histogram),
+	}
+}
+
+// WithNamespace set the prefix of metric names. The default namespace is hasura_router
+func (pr *PrometheusRecorder) WithNamespace(namespace string) *PrometheusRecorder {
+	pr.namespace = namespace
+	return pr
+}
+
+// WithBuckets set upper bounds in seconds of latency histogram buckets.
+// Histograms that were already recorded are reset because their buckets no longer match
+func (pr *PrometheusRecorder) WithBuckets(buckets ...float64) *PrometheusRecorder {
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	pr.Lock()
+	defer pr.Unlock()
+	pr.buckets = sorted
+	pr.histograms = make(map[histogramKey]*histogram)
+	pr.phases = make(map[phaseKey]*histogram)
+	return pr
+}
+
+// WithRoles set roles that are recorded in the role label.
+// Other non-empty roles are recorded as OtherRole, so the label can't grow with values from request payloads.
+// All roles are recorded as OtherRole by default
+func (pr *PrometheusRecorder) WithRoles(roles ...string) *PrometheusRecorder {
+	pr.Lock()
+	defer pr.Unlock()
+	pr.roles = make(map[string]bool, len(roles))
+	for _, role := range roles {
+		pr.roles[role] = true
+	}
+	return pr
+}
+
+func (pr *PrometheusRecorder) roleLabel(role string) string {
+	if role == "" || pr.roles[role] {
+		return role
+	}
+	return OtherRole
+}
+
+// Observe implements the Recorder interface
+func (pr *PrometheusRecorder) Observe(observation Observation) {
+	seconds := observation.Duration.Seconds()
+	status := observation.Status()
+
+	pr.Lock()
+	defer pr.Unlock()
+
+	role := pr.roleLabel(observation.Role)
+	pr.counters[counterKey{
+		router:    observation.RouterType,
+		handler:   observation.Handler,
+		role:      role,
+		status:    status,
+		errorCode: observation.ErrorCode,
+	}]++
+
+	key := histogramKey{
+		router:  observation.RouterType,
+		handler: observation.Handler,
+		role:    role,
+		status:  status,
+	}
+	h, ok := pr.histograms[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(pr.buckets))}
+		pr.histograms[key] = h
+	}
+	pr.observeHistogram(h, seconds)
+
+	for phase, duration := range observation.Phases {
+		key := phaseKey{
+			router:  observation.RouterType,
+			handler: observation.Handler,
+			phase:   phase,
+		}
+		h, ok := pr.phases[key]
+		if !ok {
+			h = &histogram{buckets: make([]uint64, len(pr.buckets))}
+			pr.phases[key] = h
+		}
+		pr.observeHistogram(h, duration.Seconds())
+	}
+}
+
+func (pr *PrometheusRecorder) observeHistogram(h *histogram, seconds float64) {
+	for i, bound := range pr.buckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP implements the http handler interface to expose metrics
+func (pr *PrometheusRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = pr.Write(w)
+}
+
+// Write writes metrics in the Prometheus text exposition format
+func (pr *PrometheusRecorder) Write(w io.Writer) error {
+	pr.Lock()
+	defer pr.Unlock()
+
+	var sb strings.Builder
+	counterName := pr.namespace + "_invocations_total"
+	fmt.Fprintf(&sb, "# HELP %s Total number of handler invocations.\n", counterName)
+	fmt.Fprintf(&sb, "# TYPE %s counter\n", counterName)
+
+	counterKeys := make([]counterKey, 0, len(pr.counters))
+	for key := range pr.counters {
+		counterKeys = append(counterKeys, key)
+	}
+	sort.Slice(counterKeys, func(i, j int) bool {
+		return counterKeys[i].String() < counterKeys[j].String()
+	})
+	for _, key := range counterKeys {
+		fmt.Fprintf(&sb, "%s{%s} %d\n", counterName, key, pr.counters[key])
+	}
+
+	histogramName := pr.namespace + "_invocation_duration_seconds"
+	fmt.Fprintf(&sb, "# HELP %s Latency of handler invocations in seconds.\n", histogramName)
+	fmt.Fprintf(&sb, "# TYPE %s histogram\n", histogramName)
+
+	histogramKeys := make([]histogramKey, 0, len(pr.histograms))
+	for key := range pr.histograms {
+		histogramKeys = append(histogramKeys, key)
+	}
+	sort.Slice(histogramKeys, func(i, j int) bool {
+		return histogramKeys[i].String() < histogramKeys[j].String()
+	})
+	for _, key := range histogramKeys {
+		pr.writeHistogram(&sb, histogramName, key.String(), pr.histograms[key])
+	}
+
+	if len(pr.phases) > 0 {
+		phaseName := pr.namespace + "_phase_duration_seconds"
+		fmt.Fprintf(&sb, "# HELP %s Latency of invocation phases in seconds.\n", phaseName)
+		fmt.Fprintf(&sb, "# TYPE %s histogram\n", phaseName)
+
+		phaseKeys := make([]phaseKey, 0, len(pr.phases))
+		for key := range pr.phases {
+			phaseKeys = append(phaseKeys, key)
+		}
+		sort.Slice(phaseKeys, func(i, j int) bool {
+			return phaseKeys[i].String() < phaseKeys[j].String()
+		})
+		for _, key := range phaseKeys {
+			pr.writeHistogram(&sb, phaseName, key.String(), pr.phases[key])
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (pr *PrometheusRecorder) writeHistogram(sb *strings.Builder, name string, labels string, h *histogram) {
+	for i, bound := range pr.buckets {
+		fmt.Fprintf(sb, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), h.buckets[i])
+	}
+	fmt.Fprintf(sb, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(sb, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(sb, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+func (key counterKey) String() string {
+	return formatLabels("router", key.router, "handler", key.handler, "role", key.role, "status", key.status, "error_code", key.errorCode)
+}
+
+func (key histogramKey) String() string {
+	return formatLabels("router", key.router, "handler", key.handler, "role", key.role, "status", key.status)
+}
+
+func (key phaseKey) String() string {
+	return formatLabels("router", key.router, "handler", key.handler, "phase", key.phase)
+}
+
+// formatLabels formats pairs of label names and values
+func formatLabels(pairs ...string) string {
+	labels := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels, fmt.Sprintf("%s=\"%s\"", pairs[i], escapeLabelValue(pairs[i+1])))
+	}
+	return strings.Join(labels, ",")
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// Context represents the common interface of action, event trigger and cron trigger contexts
+type Context interface {
+	context.Context
+	// RouterType returns the type of the router, e.g. action, event-trigger or cron-trigger
+	RouterType() string
+	// HandlerName returns the action name or the trigger name of the handler
+	HandlerName() string
+	GetHeaders() http.Header
+	GetSessionVariables() types.SessionVariables
+	GetTracing() *tracing.Tracing
+}
+
+// Handler represents a router-agnostic handler invocation with the decoded payload.
+// The payload is action.Payload, event.EventTriggerPayload or cron.EventPayload depending on the router
+type Handler func(ctx Context, payload interface{}) (interface{}, error)
+
+// Middleware wraps the handler invocation.
+// A middleware can short-circuit the invocation by returning an error without calling the next handler
+type Middleware func(next Handler) Handler
+
+// Chain wraps the handler with middlewares. The first middleware is the outermost one
+func Chain(handler Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChain(t *testing.T) {
+	var calls []string
+	newMiddleware := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx Context, payload interface{}) (interface{}, error) {
+				calls = append(calls, name)
+				if payload == name {
+					return nil, types.NewError(types.ErrCodeUnauthorized, name)
+				}
+				return next(ctx, payload)
+			}
+		}
+	}
+
+	handler := Chain(func(ctx Context, payload interface{}) (interface{}, error) {
+		calls = append(calls, "handler")
+		return payload, nil
+	}, newMiddleware("a"), newMiddleware("b"))
+
+	resp, err := handler(nil, "foo")
+	assert.NoError(t, err)
+	assert.Equal(t, "foo", resp)
+	assert.Equal(t, []string{"a", "b", "handler"}, calls)
+
+	calls = nil
+	_, err = handler(nil, "a")
+	assert.Equal(t, types.NewError(types.ErrCodeUnauthorized, "a"), err)
+	assert.Equal(t, []string{"a"}, calls)
+}
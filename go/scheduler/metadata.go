@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/hgiasac/hasura-router/go/cron"
+	"gopkg.in/yaml.v3"
+)
+
+// TriggerDefinition represents a cron trigger definition of Hasura metadata.
+// Headers are sent with every webhook request of the trigger
+type TriggerDefinition struct {
+	Name     string              `json:"name"`
+	Schedule string              `json:"schedule"`
+	Payload  json.RawMessage     `json:"payload,omitempty"`
+	Headers  []cron.HeaderConfig `json:"headers,omitempty"`
+	Comment  string              `json:"comment,omitempty"`
+}
+
+type metadataHeader struct {
+	Name         string `yaml:"name"`
+	Value        string `yaml:"value"`
+	ValueFromEnv string `yaml:"value_from_env"`
+}
+
+type metadataTrigger struct {
+	Name     string           `yaml:"name"`
+	Schedule string           `yaml:"schedule"`
+	Payload  interface{}      `yaml:"payload"`
+	Headers  []metadataHeader `yaml:"headers"`
+	Comment  string           `yaml:"comment"`
+}
+
+// LoadTriggers loads cron trigger definitions from the cron_triggers.yaml file of Hasura metadata
+func LoadTriggers(path string) ([]TriggerDefinition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTriggers(data)
+}
+
+// ParseTriggers parses cron trigger definitions from the content of the cron_triggers.yaml metadata file
+func ParseTriggers(data []byte) ([]TriggerDefinition, error) {
+	var triggers []metadataTrigger
+	if err := yaml.Unmarshal(data, &triggers); err != nil {
+		return nil, fmt.Errorf("failed to decode cron triggers: %w", err)
+	}
+
+	results := make([]TriggerDefinition, 0, len(triggers))
+	for _, trigger := range triggers {
+		definition := TriggerDefinition{
+			Name:     trigger.Name,
+			Schedule: trigger.Schedule,
+			Comment:  trigger.Comment,
+		}
+		for _, header := range trigger.Headers {
+			definition.Headers = append(definition.Headers, cron.HeaderConfig(header))
+		}
+		if trigger.Payload != nil {
+			payload, err := json.Marshal(trigger.Payload)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode the payload of cron trigger %s: %w", trigger.Name, err)
+			}
+			definition.Payload = payload
+		}
+		results = append(results, definition)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,228 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hgiasac/hasura-router/go/cron"
+)
+
+// Result represents the webhook response of a scheduled run
+type Result struct {
+	Name          string
+	ID            string
+	ScheduledTime time.Time
+	StatusCode    int
+	Body          []byte
+}
+
+type trigger struct {
+	definition TriggerDefinition
+	schedule   *Schedule
+}
+
+// Scheduler is an in-process scheduler that invokes a cron trigger handler, e.g. cron.Router,
+// with Hasura cron trigger payloads. It is useful for local development and tests without Hasura
+type Scheduler struct {
+	sync.Mutex
+	handler  http.Handler
+	triggers map[string]trigger
+	location *time.Location
+	onResult func(result Result)
+}
+
+// New creates a scheduler that invokes the cron trigger handler
+func New(handler http.Handler) *Scheduler {
+	return &Scheduler{
+		handler:  handler,
+		triggers: make(map[string]trigger),
+		location: time.UTC,
+		onResult: logResult,
+	}
+}
+
+// WithLocation set the time location of schedules. Hasura evaluates cron schedules in UTC
+func (s *Scheduler) WithLocation(location *time.Location) *Scheduler {
+	s.location = location
+	return s
+}
+
+// OnResult set a function to handle webhook responses of scheduled runs
+func (s *Scheduler) OnResult(callback func(result Result)) *Scheduler {
+	s.onResult = callback
+	return s
+}
+
+// Add registers cron trigger definitions
+func (s *Scheduler) Add(definitions ...TriggerDefinition) error {
+	s.Lock()
+	defer s.Unlock()
+	for _, definition := range definitions {
+		schedule, err := ParseSchedule(definition.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid schedule of cron trigger %s: %w", definition.Name, err)
+		}
+		s.triggers[definition.Name] = trigger{
+			definition: definition,
+			schedule:   schedule,
+		}
+	}
+	return nil
+}
+
+// Run fires triggers at their scheduled times until the context is canceled
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		now := time.Now().In(s.location)
+		nextTime, names := s.next(now)
+		if len(names) == 0 {
+			return fmt.Errorf("there is no scheduled cron trigger")
+		}
+
+		timer := time.NewTimer(nextTime.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		for _, name := range names {
+			if _, err := s.Trigger(ctx, name, nextTime); err != nil {
+				log.Printf("failed to trigger cron %s: %s", name, err)
+			}
+		}
+	}
+}
+
+// Trigger invokes the handler of the cron trigger with the scheduled time immediately
+func (s *Scheduler) Trigger(ctx context.Context, name string, scheduledTime time.Time) (*Result, error) {
+	s.Lock()
+	t, ok := s.triggers[name]
+	s.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown cron trigger %s", name)
+	}
+
+	payload := cron.EventPayload{
+		ID:            uuid.New().String(),
+		Name:          name,
+		ScheduledTime: scheduledTime.UTC(),
+		Payload:       t.definition.Payload,
+		Comment:       t.definition.Comment,
+	}
+	if len(payload.Payload) == 0 {
+		payload.Payload = json.RawMessage("{}")
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for _, header := range t.definition.Headers {
+		value, err := headerValue(header)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header of cron trigger %s: %w", name, err)
+		}
+		req.Header.Set(header.Name, value)
+	}
+
+	w := &responseRecorder{header: make(http.Header)}
+	s.handler.ServeHTTP(w, req)
+
+	result := &Result{
+		Name:          name,
+		ID:            payload.ID,
+		ScheduledTime: payload.ScheduledTime,
+		StatusCode:    w.statusCode,
+		Body:          w.body.Bytes(),
+	}
+	if result.StatusCode == 0 {
+		result.StatusCode = http.StatusOK
+	}
+	if s.onResult != nil {
+		s.onResult(*result)
+	}
+
+	return result, nil
+}
+
+// next returns the earliest activation time after the time and names of triggers activated at that time
+func (s *Scheduler) next(now time.Time) (time.Time, []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	var nextTime time.Time
+	var names []string
+	for name, t := range s.triggers {
+		activation := t.schedule.Next(now)
+		switch {
+		case activation.IsZero():
+		case nextTime.IsZero() || activation.Before(nextTime):
+			nextTime = activation
+			names = []string{name}
+		case activation.Equal(nextTime):
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return nextTime, names
+}
+
+func logResult(result Result) {
+	if result.StatusCode >= 200 && result.StatusCode < 300 {
+		return
+	}
+	log.Printf("cron trigger %s scheduled at %s failed with status %d: %s", result.Name, result.ScheduledTime.Format(time.RFC3339), result.StatusCode, string(result.Body))
+}
+
+// responseRecorder is a minimal http.ResponseWriter that records the response
+type responseRecorder struct {
+	header     http.Header
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (rr *responseRecorder) Header() http.Header {
+	return rr.header
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if rr.statusCode == 0 {
+		rr.statusCode = http.StatusOK
+	}
+	return rr.body.Write(data)
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if rr.statusCode == 0 {
+		rr.statusCode = statusCode
+	}
+}
+
+// headerValue returns the value of the header configuration, or the value of its environment variable
+func headerValue(header cron.HeaderConfig) (string, error) {
+	if header.ValueFromEnv == "" {
+		return header.Value, nil
+	}
+	value, ok := os.LookupEnv(header.ValueFromEnv)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s of header %s is not set", header.ValueFromEnv, header.Name)
+	}
+	return value, nil
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/auth"
+	"github.com/hgiasac/hasura-router/go/cron"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchedule(t *testing.T) {
+	base := time.Date(2023, time.January, 15, 10, 30, 45, 0, time.UTC)
+	fixtures := []struct {
+		Expression string
+		Expected   time.Time
+	}{
+		{"* * * * *", time.Date(2023, time.January, 15, 10, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2023, time.January, 15, 10, 45, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2023, time.January, 15, 13, 0, 0, 0, time.UTC)},
+		{"0 0 1 feb *", time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 8 * * mon-fri", time.Date(2023, time.January, 16, 8, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2023, time.January, 20, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2023, time.January, 22, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2023, time.February, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, fixture := range fixtures {
+		schedule, err := ParseSchedule(fixture.Expression)
+		assert.NoError(t, err, fixture.Expression)
+		assert.Equal(t, fixture.Expected, schedule.Next(base), fixture.Expression)
+	}
+
+	for _, expression := range []string{"* * * *", "60 * * * *", "5-1 * * * *", "*/0 * * * *", "* * * foo *"} {
+		_, err := ParseSchedule(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	data, err := os.ReadFile("../../example/hasura/metadata/cron_triggers.yaml")
+	assert.NoError(t, err)
+	definitions, err := ParseTriggers(data)
+	assert.NoError(t, err)
+	assert.Len(t, definitions, 2)
+	assert.Equal(t, "goCronFailure", definitions[0].Name)
+	assert.Equal(t, "* * * * *", definitions[0].Schedule)
+
+	var payloads []cron.EventPayload
+	router := cron.New(map[string]cron.Handler{
+		"goCronSuccess": func(ctx *cron.Context, payload cron.EventPayload) (interface{}, error) {
+			payloads = append(payloads, payload)
+			return "ok", nil
+		},
+	})
+
+	s := New(router).OnResult(nil)
+	assert.NoError(t, s.Add(definitions...))
+
+	scheduledTime := time.Date(2023, time.January, 15, 10, 31, 0, 0, time.UTC)
+	result, err := s.Trigger(context.Background(), "goCronSuccess", scheduledTime)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Equal(t, `"ok"`, string(result.Body))
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, result.ID, payloads[0].ID)
+	assert.Equal(t, scheduledTime, payloads[0].ScheduledTime)
+
+	nextTime, names := s.next(scheduledTime)
+	assert.Equal(t, scheduledTime.Add(time.Minute), nextTime)
+	assert.Equal(t, []string{"goCronFailure", "goCronSuccess"}, names)
+}
+
+func TestSchedulerHeaders(t *testing.T) {
+	definitions, err := ParseTriggers([]byte(`
+- name: secured
+  webhook: '{{ACTION_BASE_URL}}/cron'
+  schedule: '* * * * *'
+  comment: secured trigger
+  headers:
+  - name: x-webhook-secret
+    value_from_env: SCHEDULER_TEST_SECRET
+  - name: x-source
+    value: scheduler
+`))
+	assert.NoError(t, err)
+	assert.Len(t, definitions, 1)
+	assert.Equal(t, []cron.HeaderConfig{
+		{Name: "x-webhook-secret", ValueFromEnv: "SCHEDULER_TEST_SECRET"},
+		{Name: "x-source", Value: "scheduler"},
+	}, definitions[0].Headers)
+
+	var payloads []cron.EventPayload
+	var sources []string
+	router := cron.New(map[string]cron.Handler{
+		"secured": func(ctx *cron.Context, payload cron.EventPayload) (interface{}, error) {
+			payloads = append(payloads, payload)
+			sources = append(sources, ctx.Headers.Get("x-source"))
+			return "ok", nil
+		},
+	}).WithAuthenticator(auth.NewSharedSecret("secret"))
+
+	s := New(router).OnResult(nil)
+	assert.NoError(t, s.Add(definitions...))
+
+	scheduledTime := time.Date(2023, time.January, 15, 10, 31, 0, 0, time.UTC)
+	_, err = s.Trigger(context.Background(), "secured", scheduledTime)
+	assert.ErrorContains(t, err, "environment variable SCHEDULER_TEST_SECRET of header x-webhook-secret is not set")
+
+	t.Setenv("SCHEDULER_TEST_SECRET", "secret")
+	result, err := s.Trigger(context.Background(), "secured", scheduledTime)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, result.StatusCode)
+	assert.Len(t, payloads, 1)
+	assert.Equal(t, "secured trigger", payloads[0].Comment)
+	assert.Equal(t, []string{"scheduler"}, sources)
+
+	t.Setenv("SCHEDULER_TEST_SECRET", "wrong")
+	result, err = s.Trigger(context.Background(), "secured", scheduledTime)
+	assert.NoError(t, err)
+	assert.Equal(t, 401, result.StatusCode)
+	assert.Len(t, payloads, 1)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/action"
+	"github.com/hgiasac/hasura-router/go/cron"
+	"github.com/hgiasac/hasura-router/go/event"
+)
+
+const (
+	DefaultAddress         = ":8080"
+	DefaultShutdownTimeout = 30 * time.Second
+	HealthPath             = "/healthz"
+	ReadyPath              = "/readyz"
+)
+
+// Server represents a http server that mounts action, event trigger and cron trigger routers on one handler
+type Server struct {
+	address         string
+	certFile        string
+	keyFile         string
+	tlsConfig       *tls.Config
+	shutdownTimeout time.Duration
+	paths           []string
+	handlers        map[string]http.Handler
+	ready           int32
+	mu              sync.Mutex
+	listenAddr      net.Addr
+}
+
+// New create a Hasura router server
+func New() *Server {
+	return &Server{
+		address:         DefaultAddress,
+		shutdownTimeout: DefaultShutdownTimeout,
+		handlers:        make(map[string]http.Handler),
+	}
+}
+
+// WithAddress set the listen address of the server
+func (s *Server) WithAddress(address string) *Server {
+	s.address = address
+	return s
+}
+
+// WithTLS set the certificate and key files to serve HTTPS
+func (s *Server) WithTLS(certFile string, keyFile string) *Server {
+	s.certFile = certFile
+	s.keyFile = keyFile
+	return s
+}
+
+// WithTLSConfig set the TLS configuration of the server
+func (s *Server) WithTLSConfig(config *tls.Config) *Server {
+	s.tlsConfig = config
+	return s
+}
+
+// WithShutdownTimeout set the maximum duration to wait for in-flight requests on shutdown
+func (s *Server) WithShutdownTimeout(timeout time.Duration) *Server {
+	s.shutdownTimeout = timeout
+	return s
+}
+
+// WithActions mounts the action router at the path
+func (s *Server) WithActions(path string, router *action.Router) *Server {
+	return s.Handle(path, router)
+}
+
+// WithEvents mounts the event trigger router at the path
+func (s *Server) WithEvents(path string, router *event.Router) *Server {
+	return s.Handle(path, router)
+}
+
+// WithCrons mounts the cron trigger router at the path
+func (s *Server) WithCrons(path string, router *cron.Router) *Server {
+	return s.Handle(path, router)
+}
+
+// Handle mounts a custom http handler at the path
+func (s *Server) Handle(path string, handler http.Handler) *Server {
+	if _, ok := s.handlers[path]; !ok {
+		s.paths = append(s.paths, path)
+	}
+	s.handlers[path] = handler
+	return s
+}
+
+// Handler returns the http handler that serves all mounted routers and health check endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	for _, path := range s.paths {
+		mux.Handle(path, s.handlers[path])
+	}
+	mux.HandleFunc(HealthPath, func(w http.ResponseWriter, r *http.Request) {
+		writeStatus(w, http.StatusOK, "ok")
+	})
+	mux.HandleFunc(ReadyPath, func(w http.ResponseWriter, r *http.Request) {
+		if !s.IsReady() {
+			writeStatus(w, http.StatusServiceUnavailable, "unavailable")
+			return
+		}
+		writeStatus(w, http.StatusOK, "ok")
+	})
+
+	return mux
+}
+
+// IsReady checks if the server is serving and not shutting down
+func (s *Server) IsReady() bool {
+	return atomic.LoadInt32(&s.ready) == 1
+}
+
+// Addr returns the address the server is listening on, or nil if it isn't listening
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.listenAddr
+}
+
+// ListenAndServe starts the http server and blocks until the context is canceled
+// or the process receives SIGINT or SIGTERM, then gracefully shuts down the server
+// after waiting for in-flight requests and background work of routers
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	httpServer := &http.Server{
+		Addr:      s.address,
+		Handler:   s.Handler(),
+		TLSConfig: s.tlsConfig,
+	}
+
+	// bind the listener before reporting ready so a failed bind is never served as ready
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.listenAddr = listener.Addr()
+	s.mu.Unlock()
+
+	errChan := make(chan error, 1)
+	go func() {
+		var err error
+		if s.certFile != "" || s.tlsConfig != nil {
+			err = httpServer.ServeTLS(listener, s.certFile, s.keyFile)
+		} else {
+			err = httpServer.Serve(listener)
+		}
+		errChan <- err
+	}()
+
+	atomic.StoreInt32(&s.ready, 1)
+	log.Printf("running server at %s", listener.Addr())
+
+	select {
+	case err := <-errChan:
+		atomic.StoreInt32(&s.ready, 0)
+		return err
+	case <-ctx.Done():
+	}
+
+	atomic.StoreInt32(&s.ready, 0)
+	log.Printf("shutting down server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	// wait for background work of routers, e.g. asynchronous actions
+	for _, path := range s.paths {
+		if shutdowner, ok := s.handlers[path].(interface {
+			Shutdown(ctx context.Context) error
+		}); ok {
+			if err := shutdowner.Shutdown(shutdownCtx); err != nil {
+				return err
+			}
+		}
+	}
+	if err := <-errChan; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func writeStatus(w http.ResponseWriter, statusCode int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(`{"status":"` + status + `"}`))
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hgiasac/hasura-router/go/action"
+	"github.com/hgiasac/hasura-router/go/cron"
+	"github.com/hgiasac/hasura-router/go/event"
+	"github.com/stretchr/testify/assert"
+)
+
+type shutdownHandler struct {
+	http.Handler
+	shutdown bool
+}
+
+func (sh *shutdownHandler) Shutdown(ctx context.Context) error {
+	sh.shutdown = true
+	return nil
+}
+
+func TestHandler(t *testing.T) {
+	actions, err := action.New(map[action.ActionName]action.Action{
+		"hello": func(ctx *action.Context, rawBody []byte) (interface{}, error) {
+			return "action", nil
+		},
+	})
+	assert.NoError(t, err)
+	events := event.New(map[string]event.Handler{
+		"userTrigger": func(ctx *event.Context, payload event.EventTriggerPayload) (interface{}, error) {
+			return "event", nil
+		},
+	})
+	crons := cron.New(map[string]cron.Handler{
+		"report": func(ctx *cron.Context, payload cron.EventPayload) (interface{}, error) {
+			return "cron", nil
+		},
+	})
+
+	server := New().
+		WithActions("/actions", actions).
+		WithEvents("/events", events).
+		WithCrons("/crons", crons)
+	handler := server.Handler()
+
+	fixtures := []struct {
+		Name       string
+		Method     string
+		Path       string
+		Body       string
+		StatusCode int
+		Response   string
+	}{
+		{"health", http.MethodGet, HealthPath, "", http.StatusOK, `{"status":"ok"}`},
+		{"not_ready", http.MethodGet, ReadyPath, "", http.StatusServiceUnavailable, `{"status":"unavailable"}`},
+		{"action", http.MethodPost, "/actions", `{"action": {"name": "hello"}, "session_variables": {"x-hasura-role": "user"}, "input": {}}`, http.StatusOK, `"action"`},
+		{"event", http.MethodPost, "/events", `{"id": "1", "trigger": {"name": "userTrigger"}, "event": {"op": "INSERT", "session_variables": {"x-hasura-role": "admin"}, "data": {}}}`, http.StatusOK, `"event"`},
+		{"cron", http.MethodPost, "/crons", `{"id": "1", "name": "report"}`, http.StatusOK, `"cron"`},
+		{"not_found", http.MethodGet, "/unknown", "", http.StatusNotFound, "404 page not found\n"},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(fixture.Method, fixture.Path, bytes.NewReader([]byte(fixture.Body))))
+			assert.Equal(t, fixture.StatusCode, w.Code)
+			assert.Equal(t, fixture.Response, w.Body.String())
+		})
+	}
+}
+
+func TestListenAndServeBindError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	server := New().WithAddress(listener.Addr().String())
+	assert.Error(t, server.ListenAndServe(context.Background()))
+	assert.False(t, server.IsReady())
+}
+
+func TestGracefulShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	background := &shutdownHandler{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("done"))
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := New().WithAddress("127.0.0.1:0").Handle("/slow", background)
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe(ctx)
+	}()
+
+	assert.Eventually(t, server.IsReady, time.Second, time.Millisecond)
+	baseURL := "http://" + server.Addr().String()
+
+	resp, err := http.Get(baseURL + ReadyPath)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	type result struct {
+		body string
+		err  error
+	}
+	slowResult := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(baseURL + "/slow")
+		if err != nil {
+			slowResult <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		slowResult <- result{body: string(body), err: err}
+	}()
+	<-started
+
+	cancel()
+	assert.Eventually(t, func() bool {
+		return !server.IsReady()
+	}, time.Second, time.Millisecond)
+
+	// the server waits for the in-flight request before shutting down
+	select {
+	case <-serveErr:
+		t.Fatal("the server shut down before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	r := <-slowResult
+	assert.NoError(t, r.err)
+	assert.Equal(t, "done", r.body)
+	assert.NoError(t, <-serveErr)
+	assert.True(t, background.shutdown)
+}
@@ -0,0 +1,163 @@
+// Package otel adapts OpenTelemetry tracers to the tracing.Tracer interface of routers.
+// It is a separate module so the router module doesn't depend on OpenTelemetry
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracer is a tracing.Tracer that starts OpenTelemetry spans
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// NewTracer creates a tracer adapter, e.g. NewTracer(otel.Tracer("hasura-router"))
+func NewTracer(tracer trace.Tracer) *Tracer {
+	return &Tracer{tracer: tracer}
+}
+
+// Start implements the tracing.Tracer interface.
+// The parent is the current span of the context or the trace context propagated by request headers
+func (t *Tracer) Start(ctx context.Context, name string, kind tracing.SpanKind) (context.Context, tracing.Span) {
+	if parent := tracing.ParentSpanContext(ctx); parent.IsValid() {
+		if sc, err := toOtelSpanContext(parent); err == nil {
+			ctx = trace.ContextWithSpanContext(ctx, sc)
+		}
+	}
+
+	ctx, otelSpan := t.tracer.Start(ctx, name, trace.WithSpanKind(toOtelSpanKind(kind)))
+	span := &Span{span: otelSpan}
+	return tracing.ContextWithSpan(ctx, span), span
+}
+
+// Span is a tracing.Span that wraps an OpenTelemetry span
+type Span struct {
+	span trace.Span
+}
+
+// OtelSpan returns the underlying OpenTelemetry span
+func (s *Span) OtelSpan() trace.Span {
+	return s.span
+}
+
+// SpanContext implements the tracing.Span interface
+func (s *Span) SpanContext() tracing.SpanContext {
+	sc := s.span.SpanContext()
+	if !sc.IsValid() {
+		return tracing.SpanContext{}
+	}
+	return tracing.SpanContext{
+		TraceID:    sc.TraceID().String(),
+		SpanID:     sc.SpanID().String(),
+		Sampled:    sc.IsSampled(),
+		TraceState: sc.TraceState().String(),
+		Remote:     sc.IsRemote(),
+	}
+}
+
+// SetName implements the tracing.Span interface
+func (s *Span) SetName(name string) {
+	s.span.SetName(name)
+}
+
+// SetAttributes implements the tracing.Span interface
+func (s *Span) SetAttributes(attributes map[string]interface{}) {
+	results := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		results = append(results, toAttribute(key, value))
+	}
+	s.span.SetAttributes(results...)
+}
+
+// RecordError implements the tracing.Span interface
+func (s *Span) RecordError(err error) {
+	s.span.RecordError(err)
+}
+
+// SetStatus implements the tracing.Span interface
+func (s *Span) SetStatus(code tracing.StatusCode, description string) {
+	switch code {
+	case tracing.StatusOK:
+		s.span.SetStatus(codes.Ok, description)
+	case tracing.StatusError:
+		s.span.SetStatus(codes.Error, description)
+	default:
+		s.span.SetStatus(codes.Unset, description)
+	}
+}
+
+// End implements the tracing.Span interface
+func (s *Span) End() {
+	s.span.End()
+}
+
+func toOtelSpanKind(kind tracing.SpanKind) trace.SpanKind {
+	switch kind {
+	case tracing.SpanKindServer:
+		return trace.SpanKindServer
+	case tracing.SpanKindClient:
+		return trace.SpanKindClient
+	default:
+		return trace.SpanKindInternal
+	}
+}
+
+func toOtelSpanContext(sc tracing.SpanContext) (trace.SpanContext, error) {
+	traceID, err := trace.TraceIDFromHex(sc.TraceID)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	spanID, err := trace.SpanIDFromHex(sc.SpanID)
+	if err != nil {
+		return trace.SpanContext{}, err
+	}
+	// an invalid trace state is dropped rather than breaking the trace
+	traceState, _ := trace.ParseTraceState(sc.TraceState)
+
+	var flags trace.TraceFlags
+	if sc.Sampled {
+		flags = trace.FlagsSampled
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: flags,
+		TraceState: traceState,
+		Remote:     sc.Remote,
+	}), nil
+}
+
+// toAttribute converts the value to an attribute. Values of unsupported types are encoded as JSON strings
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	case []string:
+		return attribute.StringSlice(key, v)
+	case fmt.Stringer:
+		return attribute.String(key, v.String())
+	case error:
+		return attribute.String(key, v.Error())
+	}
+
+	jsonBytes, err := json.Marshal(value)
+	if err != nil {
+		return attribute.String(key, fmt.Sprint(value))
+	}
+	return attribute.String(key, string(jsonBytes))
+}
@@ -0,0 +1,65 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/tracing"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	tracer := NewTracer(provider.Tracer("test"))
+
+	headers := http.Header{}
+	headers.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, requestSpan := tracing.StartRequestSpan(context.Background(), tracer, "action", headers)
+	_, childSpan := tracing.StartSpan(ctx, "query")
+
+	tracing.EndSpan(childSpan, map[string]interface{}{"rows": 2}, nil)
+	requestSpan.SetName("action hello")
+	tracing.EndSpan(requestSpan, map[string]interface{}{
+		"action":       "hello",
+		"http_headers": headers,
+		"session":      map[string]string{"x-hasura-role": "user"},
+	}, errors.New("failed"))
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 2)
+	child, request := spans[0], spans[1]
+
+	assert.Equal(t, "action hello", request.Name())
+	assert.Equal(t, trace.SpanKindServer, request.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", request.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", request.Parent().SpanID().String())
+	assert.True(t, request.Parent().IsRemote())
+	assert.Equal(t, codes.Error, request.Status().Code)
+	assert.Equal(t, "failed", request.Status().Description)
+	assert.Len(t, request.Events(), 1)
+	assert.ElementsMatch(t, []attribute.KeyValue{
+		attribute.String("action", "hello"),
+		attribute.String("session", `{"x-hasura-role":"user"}`),
+	}, request.Attributes())
+
+	assert.Equal(t, "query", child.Name())
+	assert.Equal(t, trace.SpanKindInternal, child.SpanKind())
+	assert.Equal(t, request.SpanContext().TraceID(), child.SpanContext().TraceID())
+	assert.Equal(t, request.SpanContext().SpanID(), child.Parent().SpanID())
+	assert.Equal(t, codes.Ok, child.Status().Code)
+	assert.Equal(t, []attribute.KeyValue{attribute.Int("rows", 2)}, child.Attributes())
+
+	assert.Equal(t, tracing.SpanContext{
+		TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID:  request.SpanContext().SpanID().String(),
+		Sampled: true,
+	}, requestSpan.SpanContext())
+}
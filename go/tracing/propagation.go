@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+	HeaderB3          = "b3"
+	HeaderB3TraceID   = "x-b3-traceid"
+	HeaderB3SpanID    = "x-b3-spanid"
+	HeaderB3Sampled   = "x-b3-sampled"
+	HeaderB3Flags     = "x-b3-flags"
+)
+
+// SpanContext represents the identity of a span that is propagated across services
+type SpanContext struct {
+	// TraceID is the 32-character lowercase hex trace id
+	TraceID string
+	// SpanID is the 16-character lowercase hex span id
+	SpanID     string
+	Sampled    bool
+	TraceState string
+	Remote     bool
+}
+
+// IsValid checks if the trace id and span id are valid
+func (sc SpanContext) IsValid() bool {
+	return isValidID(sc.TraceID, 32) && isValidID(sc.SpanID, 16)
+}
+
+// TraceParent returns the W3C traceparent header value of the span context
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceID, sc.SpanID, flags)
+}
+
+// ExtractSpanContext extracts the span context from W3C trace context headers, or B3 headers as a fallback
+func ExtractSpanContext(headers http.Header) (SpanContext, bool) {
+	if sc, ok := parseTraceParent(headers.Get(HeaderTraceParent)); ok {
+		sc.TraceState = headers.Get(HeaderTraceState)
+		return sc, true
+	}
+	if sc, ok := parseB3Single(headers.Get(HeaderB3)); ok {
+		return sc, true
+	}
+	return parseB3Multi(headers)
+}
+
+// InjectSpanContext sets W3C trace context headers of the span context
+func InjectSpanContext(headers http.Header, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	headers.Set(HeaderTraceParent, sc.TraceParent())
+	if sc.TraceState != "" {
+		headers.Set(HeaderTraceState, sc.TraceState)
+	}
+}
+
+// parseTraceParent parses the traceparent header in the format version-traceid-spanid-flags
+func parseTraceParent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	// version 00 has exactly 4 parts, future versions may append more
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{
+		TraceID: strings.ToLower(parts[1]),
+		SpanID:  strings.ToLower(parts[2]),
+		Sampled: flags[0]&0x01 == 0x01,
+		Remote:  true,
+	}
+	return sc, sc.IsValid()
+}
+
+// parseB3Single parses the b3 header in the format traceid-spanid-sampled-parentspanid
+func parseB3Single(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 2 {
+		return SpanContext{}, false
+	}
+
+	sc := SpanContext{
+		TraceID: padTraceID(parts[0]),
+		SpanID:  strings.ToLower(parts[1]),
+		Sampled: true,
+		Remote:  true,
+	}
+	if len(parts) > 2 {
+		sc.Sampled = parts[2] == "1" || parts[2] == "d"
+	}
+	return sc, sc.IsValid()
+}
+
+func parseB3Multi(headers http.Header) (SpanContext, bool) {
+	sampled := headers.Get(HeaderB3Sampled)
+	sc := SpanContext{
+		TraceID: padTraceID(headers.Get(HeaderB3TraceID)),
+		SpanID:  strings.ToLower(headers.Get(HeaderB3SpanID)),
+		Sampled: sampled == "" || sampled == "1" || strings.EqualFold(sampled, "true") || headers.Get(HeaderB3Flags) == "1",
+		Remote:  true,
+	}
+	return sc, sc.IsValid()
+}
+
+// padTraceID pads 64-bit B3 trace ids to 128 bits
+func padTraceID(traceID string) string {
+	traceID = strings.ToLower(strings.TrimSpace(traceID))
+	if len(traceID) == 16 {
+		return strings.Repeat("0", 16) + traceID
+	}
+	return traceID
+}
+
+func isValidID(id string, length int) bool {
+	if len(id) != length || id == strings.Repeat("0", length) {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
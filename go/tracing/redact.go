@@ -0,0 +1,315 @@
+package tracing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// MaskStrategy represents how a sensitive value is masked
+type MaskStrategy string
+
+const (
+	// MaskRedact replaces the value with a placeholder
+	MaskRedact MaskStrategy = "redact"
+	// MaskDrop removes the value
+	MaskDrop MaskStrategy = "drop"
+	// MaskHash replaces the value with its truncated sha256 hash so equal values can still be correlated
+	MaskHash MaskStrategy = "hash"
+	// MaskPartial keeps the last 4 characters of the value and masks the rest
+	MaskPartial MaskStrategy = "partial"
+)
+
+// RedactedValue is the placeholder of redacted values
+const RedactedValue = "[REDACTED]"
+
+// DefaultDenyHeaders are headers redacted by default
+var DefaultDenyHeaders = []string{
+	types.XHasuraAdminSecret,
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-webhook-secret",
+	"x-webhook-signature",
+}
+
+// Redactor masks sensitive headers, session variables, JSON fields and structured log fields
+// before they are added to tracing or written to logs
+type Redactor struct {
+	headers          map[string]MaskStrategy
+	sessionVariables map[string]MaskStrategy
+	fields           map[string]MaskStrategy
+	paths            []pathRule
+}
+
+type pathRule struct {
+	segments []string
+	strategy MaskStrategy
+}
+
+// NewRedactor creates a redactor with the default deny list of headers
+func NewRedactor() *Redactor {
+	return NewEmptyRedactor().WithHeaders(MaskRedact, DefaultDenyHeaders...)
+}
+
+// NewEmptyRedactor creates a redactor without any rule
+func NewEmptyRedactor() *Redactor {
+	return &Redactor{
+		headers:          make(map[string]MaskStrategy),
+		sessionVariables: make(map[string]MaskStrategy),
+		fields:           make(map[string]MaskStrategy),
+	}
+}
+
+// WithHeaders adds header names, case-insensitively, to be masked with the strategy
+func (r *Redactor) WithHeaders(strategy MaskStrategy, names ...string) *Redactor {
+	for _, name := range names {
+		r.headers[strings.ToLower(name)] = strategy
+	}
+	return r
+}
+
+// WithSessionVariables adds session variable names, case-insensitively, to be masked with the strategy
+func (r *Redactor) WithSessionVariables(strategy MaskStrategy, names ...string) *Redactor {
+	for _, name := range names {
+		r.sessionVariables[strings.ToLower(name)] = strategy
+	}
+	return r
+}
+
+// WithFields adds keys of structured fields, case-insensitively, to be masked with the strategy.
+// Keys are matched in nested maps too, such as http headers and session variables
+func (r *Redactor) WithFields(strategy MaskStrategy, keys ...string) *Redactor {
+	for _, key := range keys {
+		r.fields[strings.ToLower(key)] = strategy
+	}
+	return r
+}
+
+// WithPaths adds JSON paths to be masked with the strategy. Segments are separated by dots,
+// and the wildcard * matches any object key or array element, e.g. user.password or items.*.card_number
+func (r *Redactor) WithPaths(strategy MaskStrategy, paths ...string) *Redactor {
+	for _, path := range paths {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+		if path == "" {
+			continue
+		}
+		r.paths = append(r.paths, pathRule{
+			segments: strings.Split(path, "."),
+			strategy: strategy,
+		})
+	}
+	return r
+}
+
+// RedactHeaders returns a copy of the headers with sensitive values masked
+func (r *Redactor) RedactHeaders(headers http.Header) http.Header {
+	if r == nil || len(r.headers) == 0 {
+		return headers
+	}
+
+	results := make(http.Header, len(headers))
+	for name, values := range headers {
+		strategy, ok := r.headers[strings.ToLower(name)]
+		if !ok {
+			results[name] = values
+			continue
+		}
+		masked := make([]string, 0, len(values))
+		for _, value := range values {
+			if v, keep := Mask(strategy, value); keep {
+				masked = append(masked, v)
+			}
+		}
+		if len(masked) > 0 {
+			results[name] = masked
+		}
+	}
+	return results
+}
+
+// RedactSessionVariables returns a copy of the session variables with sensitive values masked
+func (r *Redactor) RedactSessionVariables(variables map[string]string) map[string]string {
+	if r == nil || len(r.sessionVariables) == 0 {
+		return variables
+	}
+
+	results := make(map[string]string, len(variables))
+	for name, value := range variables {
+		strategy, ok := r.sessionVariables[strings.ToLower(name)]
+		if !ok {
+			results[name] = value
+			continue
+		}
+		if v, keep := Mask(strategy, value); keep {
+			results[name] = v
+		}
+	}
+	return results
+}
+
+// RedactJSON returns the JSON string with values of sensitive paths masked.
+// The input is returned as it is if it isn't a valid JSON
+func (r *Redactor) RedactJSON(data []byte) string {
+	if r == nil || len(r.paths) == 0 || len(data) == 0 {
+		return string(data)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+
+	for _, rule := range r.paths {
+		value = redactPath(value, rule.segments, rule.strategy)
+	}
+
+	results, err := json.Marshal(value)
+	if err != nil {
+		return string(data)
+	}
+	return string(results)
+}
+
+// RedactFields returns a copy of the structured fields with values of sensitive keys masked
+func (r *Redactor) RedactFields(fields map[string]interface{}) map[string]interface{} {
+	if r == nil || len(r.fields) == 0 {
+		return fields
+	}
+
+	results := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		strategy, ok := r.fields[strings.ToLower(key)]
+		if !ok {
+			results[key] = r.redactFieldValue(value)
+			continue
+		}
+		if masked, keep := Mask(strategy, stringifyJSON(value)); keep {
+			results[key] = masked
+		}
+	}
+	return results
+}
+
+func (r *Redactor) redactFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return r.RedactFields(v)
+	case http.Header:
+		return r.redactFieldStrings(v)
+	case map[string][]string:
+		return r.redactFieldStrings(v)
+	case map[string]string:
+		results := make(map[string]string, len(v))
+		for key, s := range v {
+			strategy, ok := r.fields[strings.ToLower(key)]
+			if !ok {
+				results[key] = s
+				continue
+			}
+			if masked, keep := Mask(strategy, s); keep {
+				results[key] = masked
+			}
+		}
+		return results
+	default:
+		return value
+	}
+}
+
+func (r *Redactor) redactFieldStrings(values map[string][]string) map[string][]string {
+	results := make(map[string][]string, len(values))
+	for key, items := range values {
+		strategy, ok := r.fields[strings.ToLower(key)]
+		if !ok {
+			results[key] = items
+			continue
+		}
+		masked := make([]string, 0, len(items))
+		for _, item := range items {
+			if v, keep := Mask(strategy, item); keep {
+				masked = append(masked, v)
+			}
+		}
+		if len(masked) > 0 {
+			results[key] = masked
+		}
+	}
+	return results
+}
+
+func redactPath(value interface{}, segments []string, strategy MaskStrategy) interface{} {
+	segment := segments[0]
+	last := len(segments) == 1
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if segment != "*" && segment != key {
+				continue
+			}
+			if !last {
+				v[key] = redactPath(item, segments[1:], strategy)
+				continue
+			}
+			if masked, keep := Mask(strategy, stringifyJSON(item)); keep {
+				v[key] = masked
+			} else {
+				delete(v, key)
+			}
+		}
+	case []interface{}:
+		results := make([]interface{}, 0, len(v))
+		for i, item := range v {
+			if segment != "*" && segment != strconv.Itoa(i) {
+				results = append(results, item)
+				continue
+			}
+			if !last {
+				results = append(results, redactPath(item, segments[1:], strategy))
+				continue
+			}
+			if masked, keep := Mask(strategy, stringifyJSON(item)); keep {
+				results = append(results, masked)
+			}
+		}
+		return results
+	}
+	return value
+}
+
+func stringifyJSON(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
+
+// Mask masks the value with the strategy. It returns false if the value should be dropped
+func Mask(strategy MaskStrategy, value string) (string, bool) {
+	switch strategy {
+	case MaskDrop:
+		return "", false
+	case MaskHash:
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8]), true
+	case MaskPartial:
+		runes := []rune(value)
+		if len(runes) <= 8 {
+			return strings.Repeat("*", len(runes)), true
+		}
+		return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:]), true
+	default:
+		return RedactedValue, true
+	}
+}
@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMask(t *testing.T) {
+	fixtures := []struct {
+		Strategy MaskStrategy
+		Value    string
+		Expected string
+		Keep     bool
+	}{
+		{MaskRedact, "secret", RedactedValue, true},
+		{MaskDrop, "secret", "", false},
+		{MaskHash, "secret", "sha256:2bb80d537b1da3e3", true},
+		{MaskPartial, "secret", "******", true},
+		{MaskPartial, "4111111111111111", "************1111", true},
+	}
+
+	for _, fixture := range fixtures {
+		value, keep := Mask(fixture.Strategy, fixture.Value)
+		assert.Equal(t, fixture.Expected, value, fixture.Strategy)
+		assert.Equal(t, fixture.Keep, keep, fixture.Strategy)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	redactor := NewRedactor().
+		WithHeaders(MaskDrop, "x-api-key").
+		WithSessionVariables(MaskHash, "X-Hasura-User-Id").
+		WithPaths(MaskPartial, "card.number").
+		WithPaths(MaskDrop, "users.*.password", "$.token")
+
+	headers := http.Header{}
+	headers.Set("X-Hasura-Admin-Secret", "secret")
+	headers.Set("Authorization", "Bearer token")
+	headers.Set("X-Api-Key", "key")
+	headers.Set("Content-Type", "application/json")
+	assert.Equal(t, http.Header{
+		"X-Hasura-Admin-Secret": {RedactedValue},
+		"Authorization":         {RedactedValue},
+		"Content-Type":          {"application/json"},
+	}, redactor.RedactHeaders(headers))
+	assert.Equal(t, "secret", headers.Get("X-Hasura-Admin-Secret"))
+
+	assert.Equal(t, map[string]string{
+		"x-hasura-role":    "user",
+		"x-hasura-user-id": "sha256:6b86b273ff34fce1",
+	}, redactor.RedactSessionVariables(map[string]string{
+		"x-hasura-role":    "user",
+		"x-hasura-user-id": "1",
+	}))
+
+	assert.JSONEq(t,
+		`{"card": {"number": "************1111", "cvc": 123}, "users": [{"id": 1}, {"id": 2}], "amount": 10.50}`,
+		redactor.RedactJSON([]byte(`{"card": {"number": "4111111111111111", "cvc": 123}, "users": [{"id": 1, "password": "a"}, {"id": 2, "password": "b"}], "token": "abc", "amount": 10.50}`)),
+	)
+	assert.Equal(t, "not json", redactor.RedactJSON([]byte("not json")))
+
+	redactor.WithFields(MaskDrop, "token")
+	assert.Equal(t, map[string]interface{}{
+		"request": map[string]interface{}{"id": 1},
+		"session": map[string]string{"x-hasura-role": "user"},
+	}, redactor.RedactFields(map[string]interface{}{
+		"token":   "abc",
+		"request": map[string]interface{}{"id": 1, "Token": "abc"},
+		"session": map[string]string{"x-hasura-role": "user", "token": "abc"},
+	}))
+
+	var nilRedactor *Redactor
+	assert.Equal(t, headers, nilRedactor.RedactHeaders(headers))
+}
@@ -0,0 +1,123 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// SpanData represents a completed span of the SimpleTracer
+type SpanData struct {
+	Name              string
+	Kind              SpanKind
+	SpanContext       SpanContext
+	Parent            SpanContext
+	StartTime         time.Time
+	EndTime           time.Time
+	Attributes        map[string]interface{}
+	Status            StatusCode
+	StatusDescription string
+	Errors            []error
+}
+
+// SimpleTracer is a lightweight Tracer that exports completed and sampled spans to a function.
+// It is useful for tests and logging-based tracing. Use the OpenTelemetry adapter of the go/tracing/otel module for production exporters
+type SimpleTracer struct {
+	export func(span SpanData)
+}
+
+// NewSimpleTracer creates a SimpleTracer with the export function
+func NewSimpleTracer(export func(span SpanData)) *SimpleTracer {
+	return &SimpleTracer{export: export}
+}
+
+// Start implements the Tracer interface
+func (st *SimpleTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	parent := ParentSpanContext(ctx)
+	sc := SpanContext{
+		TraceID:    parent.TraceID,
+		SpanID:     randomHex(8),
+		Sampled:    true,
+		TraceState: parent.TraceState,
+	}
+	if parent.IsValid() {
+		sc.Sampled = parent.Sampled
+	} else {
+		sc.TraceID = randomHex(16)
+	}
+
+	span := &simpleSpan{
+		tracer: st,
+		data: SpanData{
+			Name:        name,
+			Kind:        kind,
+			SpanContext: sc,
+			Parent:      parent,
+			StartTime:   time.Now(),
+			Attributes:  make(map[string]interface{}),
+		},
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+type simpleSpan struct {
+	sync.Mutex
+	tracer *SimpleTracer
+	data   SpanData
+	ended  bool
+}
+
+func (ss *simpleSpan) SpanContext() SpanContext {
+	return ss.data.SpanContext
+}
+
+func (ss *simpleSpan) SetName(name string) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.data.Name = name
+}
+
+func (ss *simpleSpan) SetAttributes(attributes map[string]interface{}) {
+	ss.Lock()
+	defer ss.Unlock()
+	for key, value := range attributes {
+		ss.data.Attributes[key] = value
+	}
+}
+
+func (ss *simpleSpan) RecordError(err error) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.data.Errors = append(ss.data.Errors, err)
+}
+
+func (ss *simpleSpan) SetStatus(code StatusCode, description string) {
+	ss.Lock()
+	defer ss.Unlock()
+	ss.data.Status = code
+	ss.data.StatusDescription = description
+}
+
+func (ss *simpleSpan) End() {
+	ss.Lock()
+	if ss.ended {
+		ss.Unlock()
+		return
+	}
+	ss.ended = true
+	ss.data.EndTime = time.Now()
+	data := ss.data
+	ss.Unlock()
+
+	if data.SpanContext.Sampled && ss.tracer.export != nil {
+		ss.tracer.export(data)
+	}
+}
+
+func randomHex(size int) string {
+	bytes := make([]byte, size)
+	_, _ = rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,172 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// SpanKind represents the role of a span in a trace
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+)
+
+// StatusCode represents the status of a span
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// Span represents a unit of work in a trace. It is designed to be easily adapted to OpenTelemetry spans
+type Span interface {
+	// SpanContext returns the identity of the span
+	SpanContext() SpanContext
+	// SetName overrides the span name
+	SetName(name string)
+	// SetAttributes sets attributes of the span
+	SetAttributes(attributes map[string]interface{})
+	// RecordError records the error as an event of the span
+	RecordError(err error)
+	// SetStatus sets the status of the span
+	SetStatus(code StatusCode, description string)
+	// End completes the span
+	End()
+}
+
+// Tracer creates spans. Implementations should return the context that carries the new span by ContextWithSpan,
+// and use ParentSpanContext to get the parent of the span
+type Tracer interface {
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+// NoopTracer is a Tracer that doesn't record anything. It is the default tracer of routers
+type NoopTracer struct{}
+
+// Start implements the Tracer interface
+func (NoopTracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	span := noopSpan{spanContext: ParentSpanContext(ctx)}
+	return ContextWithSpan(ctx, span), span
+}
+
+type noopSpan struct {
+	spanContext SpanContext
+}
+
+func (ns noopSpan) SpanContext() SpanContext                     { return ns.spanContext }
+func (noopSpan) SetName(name string)                             {}
+func (noopSpan) SetAttributes(attributes map[string]interface{}) {}
+func (noopSpan) RecordError(err error)                           {}
+func (noopSpan) SetStatus(code StatusCode, description string)   {}
+func (noopSpan) End()                                            {}
+
+type (
+	tracerContextKey     struct{}
+	spanContextKey       struct{}
+	remoteSpanContextKey struct{}
+)
+
+// ContextWithTracer returns a new context that carries the tracer
+func ContextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey{}, tracer)
+}
+
+// TracerFromContext returns the tracer stored in the context, or a NoopTracer if there is none
+func TracerFromContext(ctx context.Context) Tracer {
+	if ctx != nil {
+		if tracer, ok := ctx.Value(tracerContextKey{}).(Tracer); ok && tracer != nil {
+			return tracer
+		}
+	}
+	return NoopTracer{}
+}
+
+// ContextWithSpan returns a new context that carries the span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the current span stored in the context, or a no-op span if there is none
+func SpanFromContext(ctx context.Context) Span {
+	if ctx != nil {
+		if span, ok := ctx.Value(spanContextKey{}).(Span); ok && span != nil {
+			return span
+		}
+	}
+	return noopSpan{}
+}
+
+// ContextWithRemoteSpanContext returns a new context that carries the span context extracted from an incoming request
+func ContextWithRemoteSpanContext(ctx context.Context, spanContext SpanContext) context.Context {
+	return context.WithValue(ctx, remoteSpanContextKey{}, spanContext)
+}
+
+// ParentSpanContext returns the span context of the current span in the context,
+// or the remote span context if there is no current span
+func ParentSpanContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span, ok := ctx.Value(spanContextKey{}).(Span); ok && span != nil {
+		if sc := span.SpanContext(); sc.IsValid() {
+			return sc
+		}
+	}
+	sc, _ := ctx.Value(remoteSpanContextKey{}).(SpanContext)
+	return sc
+}
+
+// StartSpan starts a child span of the current span with the tracer in the context.
+// Handlers can use it to trace their own operations
+func StartSpan(ctx context.Context, name string) (context.Context, Span) {
+	return TracerFromContext(ctx).Start(ctx, name, SpanKindInternal)
+}
+
+// StartRequestSpan extracts the incoming trace context from request headers and starts a server span with the tracer.
+// The returned context carries the tracer and the span
+func StartRequestSpan(ctx context.Context, tracer Tracer, name string, headers http.Header) (context.Context, Span) {
+	if tracer == nil {
+		tracer = NoopTracer{}
+	}
+	ctx = ContextWithTracer(ctx, tracer)
+	if sc, ok := ExtractSpanContext(headers); ok {
+		ctx = ContextWithRemoteSpanContext(ctx, sc)
+	}
+	return tracer.Start(ctx, name, SpanKindServer)
+}
+
+// EndSpan sets tracing fields as attributes of the span, records the error and status and ends the span
+func EndSpan(span Span, fields map[string]interface{}, err error) {
+	attributes := make(map[string]interface{}, len(fields))
+	for key, value := range fields {
+		switch key {
+		case "http_headers", "measurement":
+		default:
+			attributes[key] = value
+		}
+	}
+	span.SetAttributes(attributes)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(StatusError, err.Error())
+	} else {
+		span.SetStatus(StatusOK, "")
+	}
+	span.End()
+}
+
+// Detach returns a background context that carries the tracer and the current span of the context as the remote parent.
+// It is used by background jobs that outlive the request
+func Detach(ctx context.Context) context.Context {
+	result := ContextWithTracer(context.Background(), TracerFromContext(ctx))
+	if sc := ParentSpanContext(ctx); sc.IsValid() {
+		result = ContextWithRemoteSpanContext(result, sc)
+	}
+	return result
+}
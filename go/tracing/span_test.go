@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractSpanContext(t *testing.T) {
+	fixtures := []struct {
+		Name     string
+		Headers  map[string]string
+		Expected SpanContext
+		OK       bool
+	}{
+		{
+			"traceparent",
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "tracestate": "foo=bar"},
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, TraceState: "foo=bar", Remote: true},
+			true,
+		},
+		{
+			"traceparent_not_sampled",
+			map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"},
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Remote: true},
+			true,
+		},
+		{
+			"invalid_traceparent_fallback_to_b3",
+			map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01", "b3": "a3ce929d0e0e4736-00f067aa0ba902b7-1"},
+			SpanContext{TraceID: "0000000000000000a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Sampled: true, Remote: true},
+			true,
+		},
+		{
+			"b3_multi",
+			map[string]string{"X-B3-TraceId": "4bf92f3577b34da6a3ce929d0e0e4736", "X-B3-SpanId": "00f067aa0ba902b7", "X-B3-Sampled": "0"},
+			SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7", Remote: true},
+			true,
+		},
+		{"empty", map[string]string{}, SpanContext{}, false},
+	}
+
+	for _, fixture := range fixtures {
+		headers := http.Header{}
+		for key, value := range fixture.Headers {
+			headers.Set(key, value)
+		}
+		sc, ok := ExtractSpanContext(headers)
+		assert.Equal(t, fixture.OK, ok, fixture.Name)
+		if fixture.OK {
+			assert.Equal(t, fixture.Expected, sc, fixture.Name)
+		}
+	}
+
+	headers := http.Header{}
+	InjectSpanContext(headers, fixtures[0].Expected)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", headers.Get(HeaderTraceParent))
+	assert.Equal(t, "foo=bar", headers.Get(HeaderTraceState))
+}
+
+func TestSimpleTracer(t *testing.T) {
+	var spans []SpanData
+	tracer := NewSimpleTracer(func(span SpanData) {
+		spans = append(spans, span)
+	})
+
+	headers := http.Header{}
+	headers.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := StartRequestSpan(context.Background(), tracer, "action", headers)
+
+	childCtx, child := StartSpan(ctx, "query")
+	assert.Equal(t, child, SpanFromContext(childCtx))
+	child.End()
+	child.End()
+
+	EndSpan(span, map[string]interface{}{
+		"action":       "hello",
+		"http_headers": headers,
+	}, errors.New("failed"))
+
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "query", spans[0].Name)
+	assert.Equal(t, spans[1].SpanContext, spans[0].Parent)
+	assert.Equal(t, "action", spans[1].Name)
+	assert.Equal(t, SpanKindServer, spans[1].Kind)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[1].SpanContext.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", spans[1].Parent.SpanID)
+	assert.Equal(t, map[string]interface{}{"action": "hello"}, spans[1].Attributes)
+	assert.Equal(t, StatusError, spans[1].Status)
+	assert.Equal(t, "failed", spans[1].StatusDescription)
+
+	// background contexts keep the parent span
+	_, detached := StartSpan(Detach(childCtx), "job")
+	assert.Equal(t, spans[0].SpanContext.TraceID, detached.SpanContext().TraceID)
+}
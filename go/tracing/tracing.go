@@ -1,20 +1,106 @@
 package tracing
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+const (
+	PhaseDecode   = "decode"
+	PhaseValidate = "validate"
+	PhaseHandler  = "handler"
+	PhaseEncode   = "encode"
+)
+
+// Phase represents the duration of a named checkpoint
+type Phase struct {
+	Name     string
+	Duration time.Duration
+}
+
+type phaseRecord struct {
+	name      string
+	startTime time.Time
+	endTime   time.Time
+}
+
 // TimeMeasurement store tracing checkpoints of logs ingestion process
 type TimeMeasurement struct {
 	StartTime time.Time
+	mutex     sync.Mutex
+	endTime   time.Time
+	phases    []phaseRecord
+}
+
+// End fixes the end time of the measurement. The current time is used as the end time if it isn't ended
+func (tm *TimeMeasurement) End() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	if tm.endTime.IsZero() {
+		tm.endTime = time.Now()
+	}
+}
+
+// StartPhase starts measuring the named phase and returns the function that ends it.
+// Phases that are not ended yet are measured until the time they are calculated
+func (tm *TimeMeasurement) StartPhase(name string) func() {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	tm.phases = append(tm.phases, phaseRecord{name: name, startTime: time.Now()})
+	index := len(tm.phases) - 1
+
+	return func() {
+		tm.mutex.Lock()
+		defer tm.mutex.Unlock()
+		if tm.phases[index].endTime.IsZero() {
+			tm.phases[index].endTime = time.Now()
+		}
+	}
+}
+
+// RecordPhase records the duration of the named phase
+func (tm *TimeMeasurement) RecordPhase(name string, duration time.Duration) {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+	endTime := time.Now()
+	tm.phases = append(tm.phases, phaseRecord{name: name, startTime: endTime.Add(-duration), endTime: endTime})
+}
+
+// Phases returns durations of phases in order of their first occurrence. Durations of repeated phases are summed
+func (tm *TimeMeasurement) Phases() []Phase {
+	tm.mutex.Lock()
+	defer tm.mutex.Unlock()
+
+	now := time.Now()
+	var results []Phase
+	indexes := make(map[string]int)
+	for _, record := range tm.phases {
+		endTime := record.endTime
+		if endTime.IsZero() {
+			endTime = now
+		}
+		if i, ok := indexes[record.name]; ok {
+			results[i].Duration += endTime.Sub(record.startTime)
+			continue
+		}
+		indexes[record.name] = len(results)
+		results = append(results, Phase{Name: record.name, Duration: endTime.Sub(record.startTime)})
+	}
+	return results
 }
 
 // Calculate calculate duration metrics of logs ingestion
 func (tm *TimeMeasurement) Calculate() map[string]interface{} {
 	timeM := map[string]interface{}{}
-	now := time.Now()
+	tm.mutex.Lock()
+	now := tm.endTime
+	tm.mutex.Unlock()
+	if now.IsZero() {
+		now = time.Now()
+	}
 
 	if tm.StartTime.IsZero() {
 		return timeM
@@ -23,14 +109,26 @@ func (tm *TimeMeasurement) Calculate() map[string]interface{} {
 	timeM["end"] = now
 	timeM["total_time"] = durationToMilliseconds(now.Sub(tm.StartTime))
 
+	if phases := tm.Phases(); len(phases) > 0 {
+		phaseTimes := make(map[string]float64, len(phases))
+		for _, phase := range phases {
+			phaseTimes[phase.Name] = durationToMilliseconds(phase.Duration)
+		}
+		timeM["phases"] = phaseTimes
+	}
+
 	return timeM
 }
 
-// Tracing store tracing infomation for logging
+// Tracing store tracing infomation for logging. It is safe for concurrent use
 type Tracing struct {
+	mutex       sync.RWMutex
 	requestId   string
 	fields      map[string]interface{}
 	measurement *TimeMeasurement
+	name        string
+	parent      *Tracing
+	children    []*Tracing
 }
 
 // New create new tracing context instance
@@ -49,37 +147,153 @@ func New(requestId string) *Tracing {
 	}
 }
 
-func (t Tracing) GetRequestId() string {
+func (t *Tracing) GetRequestId() string {
+	if t.parent != nil {
+		return t.parent.GetRequestId()
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 	return t.requestId
 }
 
 func (t *Tracing) SetRequestId(requestId string) {
+	if t.parent != nil {
+		t.parent.SetRequestId(requestId)
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	t.requestId = requestId
 }
 
 func (t *Tracing) WithField(key string, value interface{}) *Tracing {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	t.fields[key] = value
 	return t
 }
 
 func (t *Tracing) WithFields(fields map[string]interface{}) *Tracing {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	for k, v := range fields {
 		t.fields[k] = v
 	}
 	return t
 }
 
+// Child creates a named child scope, e.g. a sub-task that runs in a goroutine.
+// The child shares the request id and inherits fields of the parent.
+// Its own fields and timings are nested in the scopes field of the parent values
+func (t *Tracing) Child(name string) *Tracing {
+	child := &Tracing{
+		fields: make(map[string]interface{}),
+		measurement: &TimeMeasurement{
+			StartTime: time.Now(),
+		},
+		name:   name,
+		parent: t,
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.children = append(t.children, child)
+	return child
+}
+
+// End fixes the end time of the tracing measurement. It is usually called when the child scope completes
+func (t *Tracing) End() {
+	t.measurement.End()
+}
+
+// StartPhase starts measuring the named phase, e.g. a database query in the handler, and returns the function that ends it
+func (t *Tracing) StartPhase(name string) func() {
+	return t.measurement.StartPhase(name)
+}
+
+// Phases returns durations of measured phases
+func (t *Tracing) Phases() []Phase {
+	return t.measurement.Phases()
+}
+
+// StartTime returns the time the tracing instance was created
+func (t *Tracing) StartTime() time.Time {
+	return t.measurement.StartTime
+}
+
+// Values returns tracing fields, including inherited fields of parents, the request id, time measurement and child scopes
 func (t *Tracing) Values() map[string]interface{} {
 	values := make(map[string]interface{})
+	t.copyFields(values)
+	t.setScopeValues(values)
+	values["request_id"] = t.GetRequestId()
+	return values
+}
+
+// copyFields copies fields of parents and own fields to the values map
+func (t *Tracing) copyFields(values map[string]interface{}) {
+	if t.parent != nil {
+		t.parent.copyFields(values)
+	}
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
 	for k, v := range t.fields {
 		values[k] = v
 	}
-	values["request_id"] = t.requestId
+}
+
+// setScopeValues sets the time measurement and values of child scopes
+func (t *Tracing) setScopeValues(values map[string]interface{}) {
 	values["measurement"] = t.measurement.Calculate()
-	return values
+
+	t.mutex.RLock()
+	children := make([]*Tracing, len(t.children))
+	copy(children, t.children)
+	t.mutex.RUnlock()
+	if len(children) == 0 {
+		return
+	}
+
+	scopes := make([]map[string]interface{}, 0, len(children))
+	for _, child := range children {
+		scope := make(map[string]interface{})
+		child.mutex.RLock()
+		for k, v := range child.fields {
+			scope[k] = v
+		}
+		child.mutex.RUnlock()
+		scope["scope"] = child.name
+		child.setScopeValues(scope)
+		scopes = append(scopes, scope)
+	}
+	values["scopes"] = scopes
 }
 
 // durationToMilliseconds convert duration to milliseconds
 func durationToMilliseconds(d time.Duration) float64 {
 	return float64(d) / float64(time.Millisecond)
 }
+
+type contextKey struct{}
+
+// NewContext returns a new context that carries the tracing instance
+func NewContext(ctx context.Context, t *Tracing) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tracing instance stored in the context, if any
+func FromContext(ctx context.Context) *Tracing {
+	if ctx == nil {
+		return nil
+	}
+	t, _ := ctx.Value(contextKey{}).(*Tracing)
+	return t
+}
+
+// Clone creates a new tracing instance with the same request id and fields.
+// The time measurement of the new instance starts at the current time
+func (t *Tracing) Clone() *Tracing {
+	fields := make(map[string]interface{})
+	t.copyFields(fields)
+	return New(t.GetRequestId()).WithFields(fields)
+}
@@ -0,0 +1,75 @@
+package tracing
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracingPhases(t *testing.T) {
+	tracer := New("foo")
+	endDecode := tracer.StartPhase(PhaseDecode)
+	time.Sleep(5 * time.Millisecond)
+	endDecode()
+	endDecode()
+
+	tracer.measurement.RecordPhase(PhaseHandler, 10*time.Millisecond)
+	tracer.measurement.RecordPhase(PhaseHandler, 20*time.Millisecond)
+	// unfinished phases are measured until they are calculated
+	_ = tracer.StartPhase(PhaseEncode)
+
+	phases := tracer.Phases()
+	assert.Len(t, phases, 3)
+	assert.Equal(t, PhaseDecode, phases[0].Name)
+	assert.GreaterOrEqual(t, phases[0].Duration, 5*time.Millisecond)
+	assert.Equal(t, Phase{Name: PhaseHandler, Duration: 30 * time.Millisecond}, phases[1])
+	assert.Equal(t, PhaseEncode, phases[2].Name)
+
+	measurement := tracer.Values()["measurement"].(map[string]interface{})
+	phaseTimes := measurement["phases"].(map[string]float64)
+	assert.Equal(t, float64(30), phaseTimes[PhaseHandler])
+	assert.Len(t, phaseTimes, 3)
+
+	assert.Nil(t, tracer.Clone().Phases())
+}
+
+func TestTracingChild(t *testing.T) {
+	tracer := New("foo").WithField("action", "hello")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			child := tracer.Child(fmt.Sprintf("task_%d", i)).WithField("index", i)
+			defer child.End()
+			child.Child("query").WithField("table", "users").End()
+			tracer.WithField(fmt.Sprintf("field_%d", i), i)
+			_ = tracer.Values()
+		}(i)
+	}
+	wg.Wait()
+
+	values := tracer.Values()
+	assert.Equal(t, "foo", values["request_id"])
+	scopes := values["scopes"].([]map[string]interface{})
+	assert.Len(t, scopes, 10)
+	for _, scope := range scopes {
+		assert.Equal(t, fmt.Sprintf("task_%d", scope["index"]), scope["scope"])
+		assert.NotContains(t, scope, "action")
+		assert.Contains(t, scope, "measurement")
+		nested := scope["scopes"].([]map[string]interface{})
+		assert.Equal(t, "query", nested[0]["scope"])
+		assert.Equal(t, "users", nested[0]["table"])
+	}
+
+	child := tracer.Child("email").WithField("to", "foo@example.com")
+	tracer.SetRequestId("bar")
+	childValues := child.Values()
+	assert.Equal(t, "bar", childValues["request_id"])
+	assert.Equal(t, "hello", childValues["action"])
+	assert.Equal(t, "foo@example.com", childValues["to"])
+}
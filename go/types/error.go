@@ -1,6 +1,15 @@
 package types
 
-import "fmt"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+)
 
 const (
 	ErrCodeBadRequest   = "bad_request"
@@ -8,6 +17,8 @@ const (
 	ErrCodeUnauthorized = "unauthorized"
 	ErrCodeNotFound     = "not_found"
 	ErrCodeInternal     = "internal_error"
+	ErrCodeTimeout      = "timeout"
+	ErrCodeUnavailable  = "service_unavailable"
 )
 
 // Error represents the action error response object.
@@ -49,3 +60,109 @@ func NewError(code string, message string) Error {
 		Extensions: extensions,
 	}
 }
+
+// NewDecodeError creates a bad request Error from a JSON decoding error.
+// The offending field path, prefixed by the optional path segments, is added to the extensions
+func NewDecodeError(err error, path ...string) Error {
+	result := NewError(ErrCodeBadRequest, fmt.Sprintf("json body could not be decoded: %s", err.Error()))
+
+	var typeError *json.UnmarshalTypeError
+	var syntaxError *json.SyntaxError
+	switch {
+	case errors.As(err, &typeError):
+		if typeError.Field != "" {
+			path = append(path, typeError.Field)
+		}
+		result.Message = fmt.Sprintf("expected %s, got %s", typeError.Type.String(), typeError.Value)
+		result.Extensions["expected"] = typeError.Type.String()
+	case errors.As(err, &syntaxError):
+		result.Extensions["offset"] = syntaxError.Offset
+	}
+
+	if len(path) > 0 {
+		fieldPath := strings.Join(path, ".")
+		result.Message = fmt.Sprintf("%s: %s", fieldPath, result.Message)
+		result.Extensions["path"] = fieldPath
+	}
+
+	return result
+}
+
+// PanicError represents a recovered panic of a handler
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error implements the error interface.
+func (pe *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", pe.Value)
+}
+
+// Fields returns the panic value and the stack trace as tracing fields
+func (pe *PanicError) Fields() map[string]interface{} {
+	return map[string]interface{}{
+		"panic": fmt.Sprint(pe.Value),
+		"stack": string(pe.Stack),
+	}
+}
+
+// CatchPanic executes the function and converts a panic into a PanicError
+func CatchPanic(fn func() (interface{}, error)) (result interface{}, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			result = nil
+			err = &PanicError{
+				Value: value,
+				Stack: debug.Stack(),
+			}
+		}
+	}()
+
+	return fn()
+}
+
+// ExecuteWithTimeout executes the function with CatchPanic. If the timeout is positive, the function runs in a goroutine
+// with a context that is canceled after the timeout, so the caller's context keeps its own deadline.
+// The name, e.g. "action hello", is used in timeout and cancellation errors
+func ExecuteWithTimeout(ctx context.Context, name string, timeout time.Duration, fn func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	if timeout <= 0 {
+		return CatchPanic(func() (interface{}, error) {
+			return fn(ctx)
+		})
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		response interface{}
+		err      error
+	}
+	resultChan := make(chan result, 1)
+	go func() {
+		resp, err := CatchPanic(func() (interface{}, error) {
+			return fn(timeoutCtx)
+		})
+		resultChan <- result{response: resp, err: err}
+	}()
+
+	select {
+	case r := <-resultChan:
+		return r.response, r.err
+	case <-timeoutCtx.Done():
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return nil, NewError(ErrCodeTimeout, fmt.Sprintf("%s exceeded the timeout of %s", name, timeout))
+		}
+		return nil, fmt.Errorf("%s was canceled: %w", name, timeoutCtx.Err())
+	}
+}
+
+// WritePanicError writes a generic internal error with the writer function, so the panic value and the stack trace
+// aren't leaked in the response. The panic is re-thrown if repanic is true
+func WritePanicError(w http.ResponseWriter, panicErr *PanicError, writeError func(w http.ResponseWriter, err error), repanic bool) {
+	writeError(w, NewError(ErrCodeInternal, "internal server error"))
+	if repanic {
+		panic(panicErr.Value)
+	}
+}
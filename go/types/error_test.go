@@ -1,8 +1,12 @@
 package types
 
 import (
+	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -24,3 +28,87 @@ func TestUnwrapError(t *testing.T) {
 	assert.Equal(t, err.Message, err.Message)
 
 }
+
+func TestErrorStatus(t *testing.T) {
+	RegisterErrorStatus("conflict", http.StatusConflict)
+
+	fixtures := []struct {
+		Err        error
+		StatusCode int
+		Response   string
+	}{
+		{
+			Err:        NewError(ErrCodeNotFound, "not found"),
+			StatusCode: http.StatusNotFound,
+			Response:   `{"message":"not found","extensions":{"code":"not_found"}}`,
+		},
+		{
+			Err:        Error{Code: "conflict", Message: "conflict"},
+			StatusCode: http.StatusConflict,
+			Response:   `{"message":"conflict","extensions":{"code":"conflict"}}`,
+		},
+		{
+			Err:        errors.New("foo"),
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"message":"foo","extensions":{"code":"unknown"}}`,
+		},
+		{
+			Err:        NewError(ErrCodeTimeout, "timed out"),
+			StatusCode: http.StatusBadRequest,
+			Response:   `{"message":"timed out","extensions":{"code":"timeout"}}`,
+		},
+	}
+
+	for _, fixture := range fixtures {
+		w := httptest.NewRecorder()
+		WriteActionError(w, fixture.Err)
+		assert.Equal(t, fixture.StatusCode, w.Code)
+		assert.Equal(t, fixture.Response, w.Body.String())
+	}
+
+	// webhooks keep 5xx status codes so Hasura retries the delivery
+	w := httptest.NewRecorder()
+	WriteWebhookError(w, NewError(ErrCodeTimeout, "timed out"))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, http.StatusBadRequest, ActionErrorStatus(ErrCodeInternal))
+	assert.Equal(t, http.StatusBadRequest, ActionErrorStatus(ErrCodeUnavailable))
+}
+
+func TestExecuteWithTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resp, err := ExecuteWithTimeout(ctx, "action fast", time.Second, func(ctx context.Context) (interface{}, error) {
+		_, hasDeadline := ctx.Deadline()
+		return hasDeadline, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, true, resp)
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline)
+
+	_, err = ExecuteWithTimeout(ctx, "action slow", 10*time.Millisecond, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.Equal(t, NewError(ErrCodeTimeout, "action slow exceeded the timeout of 10ms"), err)
+
+	var panicErr *PanicError
+	_, err = ExecuteWithTimeout(ctx, "action panic", time.Second, func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	assert.ErrorAs(t, err, &panicErr)
+	_, err = ExecuteWithTimeout(ctx, "action panic", 0, func(ctx context.Context) (interface{}, error) {
+		panic("boom")
+	})
+	assert.ErrorAs(t, err, &panicErr)
+	assert.Equal(t, "boom", panicErr.Fields()["panic"])
+
+	canceledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	_, err = ExecuteWithTimeout(canceledCtx, "action canceled", time.Second, func(ctx context.Context) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	assert.EqualError(t, err, "action canceled was canceled: context canceled")
+}
@@ -0,0 +1,111 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+var (
+	errorStatusLock  sync.RWMutex
+	errorStatusCodes = map[string]int{
+		ErrCodeBadRequest:   http.StatusBadRequest,
+		ErrCodeUnknown:      http.StatusBadRequest,
+		ErrCodeUnauthorized: http.StatusUnauthorized,
+		ErrCodeNotFound:     http.StatusNotFound,
+		ErrCodeInternal:     http.StatusInternalServerError,
+		ErrCodeTimeout:      http.StatusGatewayTimeout,
+		ErrCodeUnavailable:  http.StatusServiceUnavailable,
+	}
+)
+
+// RegisterErrorStatus registers the HTTP status code of a custom error code
+func RegisterErrorStatus(code string, statusCode int) {
+	errorStatusLock.Lock()
+	defer errorStatusLock.Unlock()
+	errorStatusCodes[code] = statusCode
+}
+
+// ErrorStatus returns the HTTP status code of the error code.
+// Unregistered error codes are mapped to 400 Bad Request
+func ErrorStatus(code string) int {
+	errorStatusLock.RLock()
+	defer errorStatusLock.RUnlock()
+	if statusCode, ok := errorStatusCodes[code]; ok {
+		return statusCode
+	}
+	return http.StatusBadRequest
+}
+
+// ToError converts any error to an Error instance.
+// Errors that don't wrap an Error are converted with the unknown code
+func ToError(err error) Error {
+	var result Error
+	if !errors.As(err, &result) {
+		return NewError(ErrCodeUnknown, err.Error())
+	}
+	if result.Code == "" {
+		result.Code = ErrCodeUnknown
+	}
+
+	extensions := make(map[string]interface{})
+	for k, v := range result.Extensions {
+		extensions[k] = v
+	}
+	if _, ok := extensions["code"]; !ok {
+		extensions["code"] = result.Code
+	}
+	result.Extensions = extensions
+
+	return result
+}
+
+// ActionErrorResponse represents the error response body that Hasura expects from action handlers
+type ActionErrorResponse struct {
+	Message    string                 `json:"message"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// ActionErrorStatus returns the HTTP status code of the action error code.
+// Hasura only forwards the message and extensions of 4xx action responses to the client
+// and replaces 5xx responses with a generic internal error, so 5xx status codes are mapped to 400 Bad Request.
+// The original error code is still available in the code extension
+func ActionErrorStatus(code string) int {
+	statusCode := ErrorStatus(code)
+	if statusCode >= http.StatusInternalServerError {
+		return http.StatusBadRequest
+	}
+	return statusCode
+}
+
+// WriteActionError writes the error with the Hasura action error response shape and the status code of ActionErrorStatus
+func WriteActionError(w http.ResponseWriter, err error) {
+	actionError := ToError(err)
+	writeJSON(w, ActionErrorStatus(actionError.Code), ActionErrorResponse{
+		Message:    actionError.Message,
+		Extensions: actionError.Extensions,
+	})
+}
+
+// WriteWebhookError writes the error response of event and cron trigger webhooks with the mapped status code.
+// Hasura retries the delivery on any non-2xx status and stores the body in the invocation logs
+func WriteWebhookError(w http.ResponseWriter, err error) {
+	webhookError := ToError(err)
+	writeJSON(w, ErrorStatus(webhookError.Code), webhookError)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	responseBytes, err := json.Marshal(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(fmt.Sprintf(`{ "message": "ERROR: %s" }`, err)))
+		return
+	}
+
+	w.WriteHeader(statusCode)
+	w.Write(responseBytes)
+}
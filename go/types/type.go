@@ -8,3 +8,10 @@ const (
 
 	RoleAdmin string = "admin"
 )
+
+const (
+	RouterTypeAction       = "action"
+	RouterTypeAsyncAction  = "async-action"
+	RouterTypeEventTrigger = "event-trigger"
+	RouterTypeCronTrigger  = "cron-trigger"
+)
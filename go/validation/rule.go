@@ -0,0 +1,166 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// checkRuleType checks if the rule is known, its parameter is valid and it supports the field type.
+// Rules of interface fields are checked with the dynamic type when values are validated
+func checkRuleType(t reflect.Type, r rule) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch r.name {
+	case "required", "oneof":
+		return nil
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		if _, err := strconv.ParseFloat(r.param, 64); err != nil {
+			return fmt.Errorf("invalid parameter of rule %s: %s", r.name, r.param)
+		}
+		if _, ok := sizeUnit(t.Kind()); !ok && t.Kind() != reflect.Interface {
+			return fmt.Errorf("rule %s is not supported by the %s type", r.name, t)
+		}
+		return nil
+	case "email", "url", "uuid":
+		if t.Kind() != reflect.String && t.Kind() != reflect.Interface {
+			return fmt.Errorf("rule %s is not supported by the %s type", r.name, t)
+		}
+		return nil
+	}
+
+	customRulesMutex.RLock()
+	_, ok := customRules[r.name]
+	customRulesMutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown rule %s", r.name)
+	}
+	return nil
+}
+
+// checkRule checks the rule and returns the violation message if the value is invalid
+func checkRule(value reflect.Value, r rule) (string, bool, error) {
+	switch r.name {
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		return checkSize(value, r)
+	case "email":
+		s, ok := stringValue(value)
+		if !ok {
+			return "must be a valid email address", false, nil
+		}
+		address, err := mail.ParseAddress(s)
+		return "must be a valid email address", err == nil && address.Address == s, nil
+	case "url":
+		s, ok := stringValue(value)
+		if !ok {
+			return "must be a valid url", false, nil
+		}
+		u, err := url.ParseRequestURI(s)
+		return "must be a valid url", err == nil && u.Scheme != "" && u.Host != "", nil
+	case "uuid":
+		s, ok := stringValue(value)
+		return "must be a valid uuid", ok && uuidRegex.MatchString(s), nil
+	case "oneof":
+		options := strings.Fields(r.param)
+		actual := fmt.Sprint(value.Interface())
+		for _, option := range options {
+			if option == actual {
+				return "", true, nil
+			}
+		}
+		return fmt.Sprintf("must be one of [%s]", strings.Join(options, ", ")), false, nil
+	}
+
+	customRulesMutex.RLock()
+	custom, ok := customRules[r.name]
+	customRulesMutex.RUnlock()
+	if !ok {
+		return "", false, fmt.Errorf("unknown rule %s", r.name)
+	}
+	message := custom.message
+	if strings.Contains(message, "%s") {
+		message = fmt.Sprintf(message, r.param)
+	}
+	return message, custom.check(value, r.param), nil
+}
+
+// checkSize compares the numeric value, or the length of strings, slices and maps, with the rule parameter
+func checkSize(value reflect.Value, r rule) (string, bool, error) {
+	param, err := strconv.ParseFloat(r.param, 64)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid parameter of rule %s: %s", r.name, r.param)
+	}
+	unit, ok := sizeUnit(value.Kind())
+	if !ok {
+		return "", false, fmt.Errorf("rule %s is not supported by the %s type", r.name, value.Type())
+	}
+
+	var size float64
+	switch value.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(value.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(value.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(value.Uint())
+	default:
+		size = value.Float()
+	}
+
+	switch r.name {
+	case "min":
+		if unit != "" {
+			return fmt.Sprintf("must have at least %s%s", r.param, unit), size >= param, nil
+		}
+		return fmt.Sprintf("must be greater than or equal to %s", r.param), size >= param, nil
+	case "max":
+		if unit != "" {
+			return fmt.Sprintf("must have at most %s%s", r.param, unit), size <= param, nil
+		}
+		return fmt.Sprintf("must be less than or equal to %s", r.param), size <= param, nil
+	case "len":
+		return fmt.Sprintf("must have exactly %s%s", r.param, unit), size == param, nil
+	case "gt":
+		return fmt.Sprintf("must be greater than %s%s", r.param, unit), size > param, nil
+	case "gte":
+		return fmt.Sprintf("must be greater than or equal to %s%s", r.param, unit), size >= param, nil
+	case "lt":
+		return fmt.Sprintf("must be less than %s%s", r.param, unit), size < param, nil
+	default:
+		return fmt.Sprintf("must be less than or equal to %s%s", r.param, unit), size <= param, nil
+	}
+}
+
+// sizeUnit returns the unit of violation messages of size rules, and false if the kind has no size
+func sizeUnit(kind reflect.Kind) (string, bool) {
+	switch kind {
+	case reflect.String:
+		return " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "", true
+	default:
+		return "", false
+	}
+}
+
+func stringValue(value reflect.Value) (string, bool) {
+	if value.Kind() != reflect.String {
+		return "", false
+	}
+	return value.String(), true
+}
@@ -0,0 +1,342 @@
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hgiasac/hasura-router/go/types"
+)
+
+// TagName is the struct tag of validation rules, e.g. `validate:"required,min=3,max=50"`
+const TagName = "validate"
+
+// Violation represents a failed validation rule of a field
+type Violation struct {
+	// Path is the dot-separated JSON path of the field, e.g. address.city or items.0.name
+	Path string `json:"path"`
+	// Rule is the machine-readable rule code, e.g. required, min, email
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// RuleFunc checks if the value satisfies the rule with the parameter
+type RuleFunc func(value reflect.Value, param string) bool
+
+type customRule struct {
+	check   RuleFunc
+	message string
+}
+
+var (
+	customRulesMutex sync.RWMutex
+	customRules      = make(map[string]customRule)
+)
+
+// RegisterRule registers a custom rule that can be used in struct tags.
+// The message is formatted with the rule parameter, e.g. "must be a multiple of %s"
+func RegisterRule(name string, check RuleFunc, message string) {
+	customRulesMutex.Lock()
+	customRules[name] = customRule{check: check, message: message}
+	customRulesMutex.Unlock()
+
+	// plans may have failed with the rule unknown
+	plansMutex.Lock()
+	plans = make(map[reflect.Type]plan)
+	plansMutex.Unlock()
+}
+
+// Check parses struct tag rules of the type and its nested types. It returns an error if a rule is unknown,
+// has an invalid parameter or doesn't support the field type, so invalid tags can be found at registration.
+// Custom rules must be registered before the check
+func Check(t reflect.Type) error {
+	if t == nil {
+		return nil
+	}
+	return checkType(t, make(map[reflect.Type]bool))
+}
+
+// Validate validates the value by struct tag rules and returns a bad_request types.Error that lists all violations,
+// or nil if the value is valid. Invalid struct tags are returned as an internal_error
+func Validate(value interface{}) error {
+	violations, err := Violations(value)
+	if err != nil {
+		return types.NewError(types.ErrCodeInternal, err.Error())
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return NewError(violations)
+}
+
+// NewError creates a bad_request error with violations in the violations extension
+func NewError(violations []Violation) types.Error {
+	messages := make([]string, len(violations))
+	for i, violation := range violations {
+		messages[i] = violation.Message
+	}
+	err := types.NewError(types.ErrCodeBadRequest, fmt.Sprintf("validation failed: %s", strings.Join(messages, "; ")))
+	err.Extensions["violations"] = violations
+	return err
+}
+
+// Violations validates the value by struct tag rules and returns all violations.
+// It returns an error if struct tags of the value are invalid, see Check
+func Violations(value interface{}) ([]Violation, error) {
+	var violations []Violation
+	if err := validateValue("", reflect.ValueOf(value), &violations); err != nil {
+		return nil, err
+	}
+	return violations, nil
+}
+
+type rule struct {
+	name  string
+	param string
+}
+
+type fieldPlan struct {
+	index     int
+	name      string
+	anonymous bool
+	omitEmpty bool
+	rules     []rule
+}
+
+type plan struct {
+	fields []fieldPlan
+	err    error
+}
+
+var (
+	plansMutex sync.RWMutex
+	plans      = make(map[reflect.Type]plan)
+)
+
+// getPlan returns cached validation plans of struct fields, or the error of invalid struct tags
+func getPlan(t reflect.Type) ([]fieldPlan, error) {
+	plansMutex.RLock()
+	p, ok := plans[t]
+	plansMutex.RUnlock()
+	if ok {
+		return p.fields, p.err
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name := field.Name
+		jsonName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if jsonName == "-" {
+			continue
+		}
+		if jsonName != "" {
+			name = jsonName
+		}
+
+		fp := fieldPlan{
+			index:     i,
+			name:      name,
+			anonymous: field.Anonymous && jsonName == "",
+		}
+		for _, item := range strings.Split(field.Tag.Get(TagName), ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if item == "omitempty" {
+				fp.omitEmpty = true
+				continue
+			}
+			ruleName, param, _ := strings.Cut(item, "=")
+			r := rule{name: ruleName, param: param}
+			if err := checkRuleType(field.Type, r); err != nil && p.err == nil {
+				p.err = fmt.Errorf("validation: field %s of %s: %w", field.Name, t, err)
+			}
+			fp.rules = append(fp.rules, r)
+		}
+		p.fields = append(p.fields, fp)
+	}
+
+	plansMutex.Lock()
+	plans[t] = p
+	plansMutex.Unlock()
+	return p.fields, p.err
+}
+
+// checkType checks plans of struct types that validateValue may visit
+func checkType(t reflect.Type, visited map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return checkType(t.Elem(), visited)
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	fields, err := getPlan(t)
+	if err != nil {
+		return err
+	}
+	for _, fp := range fields {
+		field := t.Field(fp.index)
+		if field.PkgPath != "" {
+			continue
+		}
+		if err := checkType(field.Type, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateValue(path string, value reflect.Value, violations *[]Violation) error {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		fields, err := getPlan(value.Type())
+		if err != nil {
+			return err
+		}
+		for _, fp := range fields {
+			field := value.Field(fp.index)
+			fieldPath := path
+			if !fp.anonymous {
+				fieldPath = joinPath(path, fp.name)
+			}
+			if fp.omitEmpty && field.IsZero() {
+				continue
+			}
+			ok, err := checkRules(fieldPath, field, fp.rules, violations)
+			if err != nil {
+				return err
+			}
+			if !ok || !field.CanInterface() {
+				continue
+			}
+			if err := validateValue(fieldPath, field, violations); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if !containsStruct(value.Type().Elem()) {
+			return nil
+		}
+		for i := 0; i < value.Len(); i++ {
+			if err := validateValue(joinPath(path, strconv.Itoa(i)), value.Index(i), violations); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if !containsStruct(value.Type().Elem()) {
+			return nil
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			if err := validateValue(joinPath(path, fmt.Sprint(iter.Key().Interface())), iter.Value(), violations); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkRules checks rules of the field and returns false if the field is a nil pointer
+func checkRules(path string, value reflect.Value, rules []rule, violations *[]Violation) (bool, error) {
+	for _, r := range rules {
+		if r.name != "required" {
+			continue
+		}
+		if isEmpty(value) {
+			*violations = append(*violations, newViolation(path, r, "is required"))
+			return false, nil
+		}
+	}
+
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return false, nil
+		}
+		value = value.Elem()
+	}
+
+	for _, r := range rules {
+		if r.name == "required" {
+			continue
+		}
+		message, ok, err := checkRule(value, r)
+		if err != nil {
+			return false, fmt.Errorf("validation: field %s: %w", path, err)
+		}
+		if !ok {
+			*violations = append(*violations, newViolation(path, r, message))
+		}
+	}
+	return true, nil
+}
+
+func newViolation(path string, r rule, message string) Violation {
+	name := path
+	if name == "" {
+		name = "value"
+	}
+	return Violation{
+		Path:    path,
+		Rule:    r.name,
+		Param:   r.param,
+		Message: fmt.Sprintf("%s %s", name, message),
+	}
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	case reflect.Slice, reflect.Map, reflect.String:
+		return value.Len() == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// containsStruct checks if the type may contain struct fields to be validated
+func containsStruct(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return containsStruct(t.Elem())
+	default:
+		return false
+	}
+}
+
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
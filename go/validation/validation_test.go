@@ -0,0 +1,169 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hgiasac/hasura-router/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type address struct {
+	City    string `json:"city" validate:"required"`
+	ZipCode string `json:"zip_code" validate:"omitempty,len=5"`
+}
+
+type item struct {
+	Name     string `json:"name" validate:"required,max=10"`
+	Quantity int    `json:"quantity" validate:"gt=0,lte=100"`
+}
+
+type Base struct {
+	ID string `json:"id" validate:"omitempty,uuid"`
+}
+
+type createOrderInput struct {
+	Base
+	Email    string             `json:"email" validate:"required,email"`
+	Name     string             `json:"name" validate:"min=3,max=5"`
+	Age      *int               `json:"age" validate:"omitempty,gte=18"`
+	Status   string             `json:"status" validate:"oneof=draft published"`
+	Website  string             `json:"website,omitempty" validate:"omitempty,url"`
+	Tags     []string           `json:"tags" validate:"max=2"`
+	Address  *address           `json:"address" validate:"required"`
+	Items    []item             `json:"items" validate:"min=1"`
+	Metadata map[string]address `json:"metadata"`
+	Code     int                `json:"code" validate:"even"`
+	Ignored  string             `json:"-" validate:"required"`
+}
+
+func TestValidate(t *testing.T) {
+	RegisterRule("even", func(value reflect.Value, param string) bool {
+		return value.Int()%2 == 0
+	}, "must be an even number")
+
+	age := 16
+	input := createOrderInput{
+		Base:     Base{ID: "foo"},
+		Email:    "foo",
+		Name:     "Jo",
+		Age:      &age,
+		Status:   "archived",
+		Website:  "example.com",
+		Tags:     []string{"a", "b", "c"},
+		Items:    []item{{Name: "apple", Quantity: 1}, {Name: "", Quantity: 101}},
+		Metadata: map[string]address{"home": {ZipCode: "123"}},
+		Code:     3,
+	}
+
+	violations, err := Violations(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []Violation{
+		{Path: "id", Rule: "uuid", Message: "id must be a valid uuid"},
+		{Path: "email", Rule: "email", Message: "email must be a valid email address"},
+		{Path: "name", Rule: "min", Param: "3", Message: "name must have at least 3 characters"},
+		{Path: "age", Rule: "gte", Param: "18", Message: "age must be greater than or equal to 18"},
+		{Path: "status", Rule: "oneof", Param: "draft published", Message: "status must be one of [draft, published]"},
+		{Path: "website", Rule: "url", Message: "website must be a valid url"},
+		{Path: "tags", Rule: "max", Param: "2", Message: "tags must have at most 2 items"},
+		{Path: "address", Rule: "required", Message: "address is required"},
+		{Path: "items.1.name", Rule: "required", Message: "items.1.name is required"},
+		{Path: "items.1.quantity", Rule: "lte", Param: "100", Message: "items.1.quantity must be less than or equal to 100"},
+		{Path: "metadata.home.city", Rule: "required", Message: "metadata.home.city is required"},
+		{Path: "metadata.home.zip_code", Rule: "len", Param: "5", Message: "metadata.home.zip_code must have exactly 5 characters"},
+		{Path: "code", Rule: "even", Message: "code must be an even number"},
+	}, violations)
+
+	age = 20
+	valid := createOrderInput{
+		Base:    Base{ID: "c5ab16ad-7a9f-4fd0-9f4c-6b7f4d3cbdc8"},
+		Email:   "foo@example.com",
+		Name:    "John",
+		Age:     &age,
+		Status:  "draft",
+		Website: "https://example.com",
+		Address: &address{City: "Hanoi", ZipCode: "10000"},
+		Items:   []item{{Name: "apple", Quantity: 1}},
+	}
+	assert.NoError(t, Validate(valid))
+	assert.NoError(t, Validate(nil))
+
+	err = Validate(&createOrderInput{Name: "John", Status: "draft", Items: []item{{Name: "apple", Quantity: 1}}})
+	assert.Equal(t, types.Error{
+		Code:    types.ErrCodeBadRequest,
+		Message: "validation failed: email is required; address is required",
+		Extensions: map[string]interface{}{
+			"code": types.ErrCodeBadRequest,
+			"violations": []Violation{
+				{Path: "email", Rule: "required", Message: "email is required"},
+				{Path: "address", Rule: "required", Message: "address is required"},
+			},
+		},
+	}, err)
+}
+
+type invalidParamInput struct {
+	Name string `json:"name" validate:"min=abc"`
+}
+
+type unsupportedTypeInput struct {
+	Enabled *bool `json:"enabled" validate:"max=1"`
+}
+
+type nestedInvalidInput struct {
+	Items []map[string]unsupportedTypeInput `json:"items"`
+}
+
+type recursiveInput struct {
+	Name     string            `json:"name" validate:"required"`
+	Children []*recursiveInput `json:"children"`
+}
+
+type customRuleInput struct {
+	Code int `json:"code" validate:"odd"`
+}
+
+type interfaceInput struct {
+	Value interface{} `json:"value" validate:"min=1"`
+}
+
+func TestCheck(t *testing.T) {
+	assert.NoError(t, Check(nil))
+	assert.NoError(t, Check(reflect.TypeOf(1)))
+	assert.NoError(t, Check(reflect.TypeOf(&recursiveInput{})))
+	assert.NoError(t, Check(reflect.TypeOf(interfaceInput{})))
+
+	fixtures := []struct {
+		Name  string
+		Value interface{}
+		Error string
+	}{
+		{"invalid_param", invalidParamInput{}, "validation: field Name of validation.invalidParamInput: invalid parameter of rule min: abc"},
+		{"unsupported_type", unsupportedTypeInput{}, "validation: field Enabled of validation.unsupportedTypeInput: rule max is not supported by the bool type"},
+		{"nested", &nestedInvalidInput{Items: []map[string]unsupportedTypeInput{{"a": {}}}}, "validation: field Enabled of validation.unsupportedTypeInput: rule max is not supported by the bool type"},
+		{"unknown_rule", customRuleInput{}, "validation: field Code of validation.customRuleInput: unknown rule odd"},
+	}
+
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			assert.EqualError(t, Check(reflect.TypeOf(fixture.Value)), fixture.Error)
+
+			// invalid tags are internal errors rather than panics at request time
+			assert.Equal(t, types.NewError(types.ErrCodeInternal, fixture.Error), Validate(fixture.Value))
+		})
+	}
+
+	RegisterRule("odd", func(value reflect.Value, param string) bool {
+		return value.Int()%2 == 1
+	}, "must be an odd number")
+	assert.NoError(t, Check(reflect.TypeOf(customRuleInput{})))
+	assert.NoError(t, Validate(customRuleInput{Code: 1}))
+}
+
+func TestValidateInterfaceField(t *testing.T) {
+	assert.NoError(t, Validate(interfaceInput{Value: "foo"}))
+	assert.Equal(t, types.ErrCodeBadRequest, types.ToError(Validate(interfaceInput{Value: ""})).Code)
+
+	err := Validate(interfaceInput{Value: true})
+	assert.Equal(t, types.NewError(types.ErrCodeInternal, "validation: field value: rule min is not supported by the bool type"), err)
+}